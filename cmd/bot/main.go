@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/command"
 	"github.com/kench/komikan-go/internal/config"
+	"github.com/kench/komikan-go/internal/cover"
 	"github.com/kench/komikan-go/internal/db"
 	"github.com/kench/komikan-go/internal/manga"
 	"github.com/kench/komikan-go/internal/nostr"
+	"github.com/kench/komikan-go/internal/relaysync"
 )
 
 var (
@@ -41,24 +48,60 @@ func main() {
 	cfg.LoadFromEnv()
 
 	// Validate configuration
-	if cfg.Nostr.SecretKey == "" {
-		log.Fatal("Nostr secret key is required. Set it in config.yaml or NOSTR_SECRET_KEY env var")
+	if cfg.Nostr.SecretKey == "" && cfg.Nostr.Bunker == "" {
+		log.Fatal("Nostr secret key or bunker URL is required. Set it in config.yaml or NOSTR_SECRET_KEY / NOSTR_BUNKER env var")
 	}
 	if cfg.Rakuten.ApplicationID == "" {
 		log.Fatal("Rakuten Application ID is required. Set it in config.yaml or RAKUTEN_APP_ID env var")
 	}
 
+	// Members each have their own library
+	var members []string
+	for _, m := range cfg.Bot.Members {
+		pk, err := nostr.DecodePublicKey(m)
+		if err != nil {
+			log.Fatalf("Invalid member %s: %v", m, err)
+		}
+		members = append(members, pk)
+	}
+
 	// Initialize database
-	database, err := db.NewDB(db.Config{Path: cfg.Database.Path})
+	database, err := db.NewDB(db.Config{
+		Path:   cfg.Database.Path,
+		Driver: cfg.Database.Driver,
+		Badger: db.BadgerOptions(cfg.Database.Badger),
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.Close()
 
-	// Initialize Nostr client
+	// Initialize Nostr client; events are queued in the database until
+	// enough relays accept them
+	outboxMaxAge, err := time.ParseDuration(cfg.Nostr.OutboxMaxAge)
+	if err != nil {
+		log.Printf("Invalid outbox max age: %v, using 24 hours", err)
+		outboxMaxAge = 24 * time.Hour
+	}
+	signerCtx, cancelSigner := context.WithTimeout(context.Background(), 2*time.Minute)
+	signer, err := nostr.NewSigner(signerCtx, nostr.SignerConfig{
+		SecretKey:           cfg.Nostr.SecretKey,
+		Passphrase:          cfg.Passphrase,
+		Bunker:              cfg.Nostr.Bunker,
+		BunkerClientKeyFile: cfg.Nostr.BunkerClientKeyFile,
+	})
+	cancelSigner()
+	if err != nil {
+		log.Fatalf("Failed to set up signer: %v", err)
+	}
 	client, err := nostr.NewClient(nostr.Config{
-		SecretKey: cfg.Nostr.SecretKey,
-		Relays:    cfg.Nostr.Relays,
+		Signer:        signer,
+		Relays:        cfg.Nostr.Relays,
+		Outbox:        nostr.NewOutbox(database),
+		Quorum:        cfg.Nostr.Quorum,
+		OutboxMaxAge:  outboxMaxAge,
+		MediaServer:   cfg.Nostr.MediaServer,
+		MediaProtocol: cfg.Nostr.MediaProtocol,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create Nostr client: %v", err)
@@ -78,6 +121,21 @@ func main() {
 		log.Printf("Bot public key: %s", npub)
 	}
 
+	// Keep the profile and NIP-65 relay list in line with the config
+	profile := cfg.Nostr.Profile
+	if err := client.PublishProfile(nostr.Profile{
+		Name:    profile.Name,
+		About:   profile.About,
+		Picture: profile.Picture,
+		NIP05:   profile.NIP05,
+		LUD16:   profile.LUD16,
+	}); err != nil {
+		log.Printf("Warning: failed to publish profile: %v", err)
+	}
+	if err := client.PublishRelayList(); err != nil {
+		log.Printf("Warning: failed to publish relay list: %v", err)
+	}
+
 	// Post startup announcement
 	if err := client.Publish("📚 Komikan Bot is now running!"); err != nil {
 		log.Printf("Warning: failed to publish startup message: %v", err)
@@ -85,9 +143,61 @@ func main() {
 
 	log.Println("Bot is running. Press Ctrl+C to stop.")
 
+	// Open the cover cache for announcement images
+	var covers *cover.Cache
+	if cfg.Bot.AttachCovers {
+		covers, err = cover.NewCache(cfg.Covers.Dir, cfg.Covers.MaxSizeMB<<20)
+		if err != nil {
+			log.Printf("Warning: covers disabled: %v", err)
+		}
+	}
+
 	// Start periodic checks if enabled
 	if cfg.Bot.AnnounceNewReleases {
-		go runPeriodicChecks(client, database, cfg)
+		go runPeriodicChecks(client, database, covers, cfg, members)
+	}
+
+	// Start wishlist price and stock checks if enabled
+	if cfg.Bot.AnnouncePriceChanges || cfg.Bot.AnnounceStockChanges {
+		go runPriceChecks(client, database, cfg, members)
+	}
+
+	// Start loan due-date reminders if anyone is to receive them
+	if len(cfg.Bot.Owners) > 0 || len(members) > 0 {
+		go runLoanReminders(client, database, cfg, members)
+	}
+
+	// Sync the database with other installations through relays
+	if cfg.Sync.Enabled {
+		go runRelaySync(client, database, cfg)
+	}
+
+	// Keep the library published as NIP-51 lists
+	if cfg.Bot.PublishLists {
+		go runListSync(client, database, members)
+	}
+
+	// Start scheduled value log GC
+	go runScheduledGC(database, cfg)
+
+	// Start scheduled snapshots if configured
+	if cfg.Backup.Dir != "" {
+		go runScheduledBackups(database, cfg)
+	}
+
+	// Accept commands by mention and DM: subscriptions from anyone,
+	// library management from owners and members
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cfg.Bot.Commands {
+		if len(cfg.Bot.Owners) == 0 && len(members) == 0 {
+			log.Println("Warning: no owners or members configured; only subscription commands are available")
+		}
+		// Tell clients where to send NIP-17 messages
+		if err := client.PublishDMRelays(); err != nil {
+			log.Printf("Warning: failed to publish DM relay list: %v", err)
+		}
+		go listenForCommands(ctx, client, database, cfg)
 	}
 
 	// Wait for interrupt signal
@@ -96,11 +206,35 @@ func main() {
 	<-sigChan
 
 	log.Println("Shutting down...")
+	cancel()
 	client.Disconnect()
 	fmt.Println("Bye!")
 }
 
-func runPeriodicChecks(client *nostr.Client, database *db.DB, cfg *config.Config) {
+func listenForCommands(ctx context.Context, client *nostr.Client, database *db.DB, cfg *config.Config) {
+	handler, err := command.NewHandler(manga.NewManager(database), api.NewRakutenClient(cfg.Rakuten.ApplicationID), cfg.Bot.Owners, cfg.Bot.Members)
+	if err != nil {
+		log.Printf("Commands disabled: %v", err)
+		return
+	}
+
+	log.Println("Listening for commands...")
+	err = client.Listen(ctx, func(msg nostr.Message) {
+		reply := handler.Handle(msg.From, msg.Content)
+		if reply == "" {
+			return
+		}
+		log.Printf("Command from %s: %s", msg.From, msg.Content)
+		if err := client.Reply(msg, reply); err != nil {
+			log.Printf("Failed to reply: %v", err)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Stopped listening for commands: %v", err)
+	}
+}
+
+func runPeriodicChecks(client *nostr.Client, database *db.DB, covers *cover.Cache, cfg *config.Config, members []string) {
 	// Parse check interval
 	interval, err := time.ParseDuration(cfg.Bot.CheckInterval)
 	if err != nil {
@@ -109,21 +243,374 @@ func runPeriodicChecks(client *nostr.Client, database *db.DB, cfg *config.Config
 	}
 
 	// Initial check on startup
-	checkAndAnnounceNewReleases(client, database, cfg.Rakuten.ApplicationID)
+	checkAndAnnounceNewReleases(client, database, covers, cfg.Rakuten.ApplicationID, members)
+	checkAndAnnounceAuthorReleases(client, database, cfg, members)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkAndAnnounceNewReleases(client, database, covers, cfg.Rakuten.ApplicationID, members)
+		checkAndAnnounceAuthorReleases(client, database, cfg, members)
+	}
+}
+
+func runPriceChecks(client *nostr.Client, database *db.DB, cfg *config.Config, members []string) {
+	interval, err := time.ParseDuration(cfg.Bot.PriceCheckInterval)
+	if err != nil {
+		log.Printf("Invalid price check interval: %v, using 12 hours", err)
+		interval = 12 * time.Hour
+	}
+
+	// Initial check on startup
+	checkPrices(client, database, cfg, members)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkPrices(client, database, cfg, members)
+	}
+}
+
+func checkPrices(client *nostr.Client, database *db.DB, cfg *config.Config, members []string) {
+	log.Println("Checking wishlist prices and stock...")
+
+	provider := api.NewRakutenClient(cfg.Rakuten.ApplicationID)
+	for _, mgr := range libraries(database, members) {
+		alerts, err := mgr.CheckPrices(provider, time.Second)
+		if err != nil {
+			log.Printf("Failed to check prices: %v", err)
+		}
+
+		for _, alert := range alerts {
+			enabled := cfg.Bot.AnnounceStockChanges
+			if manga.IsPriceAlert(alert.Kind) {
+				enabled = cfg.Bot.AnnouncePriceChanges
+			}
+			if !enabled {
+				continue
+			}
+			notifyLibrary(client, cfg, mgr.User(), formatPriceAlert(alert))
+			log.Printf("Price alert (%s): %s", alert.Kind, alert.Manga.Title)
+		}
+	}
+}
+
+// libraries returns the shared library followed by the member libraries
+func libraries(database *db.DB, members []string) []*manga.Manager {
+	shared := manga.NewManager(database)
+	libs := []*manga.Manager{shared}
+	for _, pk := range members {
+		libs = append(libs, shared.ForUser(pk))
+	}
+	return libs
+}
+
+// notifyLibrary sends a message about a library to its member by private
+// message, or for the shared library as notify does
+func notifyLibrary(client *nostr.Client, cfg *config.Config, member, message string) {
+	if member == "" {
+		notify(client, cfg, message)
+		return
+	}
+	if err := client.SendPrivateMessage(member, message); err != nil {
+		log.Printf("Failed to send notification to %s: %v", member, err)
+	}
+}
+
+// notify sends a message to the owners by DM, or to the timeline if none are set
+func notify(client *nostr.Client, cfg *config.Config, message string) {
+	if len(cfg.Bot.Owners) == 0 {
+		if err := client.Publish(message); err != nil {
+			log.Printf("Failed to publish notification: %v", err)
+		}
+		return
+	}
+	for _, owner := range cfg.Bot.Owners {
+		if err := client.SendDirectMessage(owner, message); err != nil {
+			log.Printf("Failed to send notification to %s: %v", owner, err)
+		}
+	}
+}
+
+func formatPriceAlert(alert manga.PriceAlert) string {
+	prev, cur := alert.Previous, alert.Current
+
+	var head string
+	switch alert.Kind {
+	case manga.AlertPriceDrop:
+		head = fmt.Sprintf("💴 値下がり: %d円 → %d円", prev.Price, cur.Price)
+	case manga.AlertBonusPoints:
+		head = fmt.Sprintf("🎁 ポイント%d倍", cur.PointRate)
+	case manga.AlertRestock:
+		head = "📦 在庫が復活しました"
+	case manga.AlertPreorder:
+		head = "📝 予約受付が始まりました"
+	case manga.AlertLowStock:
+		head = "⏳ 限定版が品切れ間近です"
+	case manga.AlertSoldOut:
+		head = "🚫 限定版が品切れになりました"
+	}
+
+	return fmt.Sprintf("%s\n\n"+
+		"%s\n"+
+		"💴 価格: %d円\n"+
+		"📦 在庫: %s\n"+
+		"🔗 %s",
+		head,
+		alert.Manga.Title,
+		cur.Price,
+		api.StockLabel(cur.State()),
+		alert.Manga.URL)
+}
+
+func runLoanReminders(client *nostr.Client, database *db.DB, cfg *config.Config, members []string) {
+	// Initial check on startup
+	for _, mgr := range libraries(database, members) {
+		sendLoanReminders(client, mgr, cfg)
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, mgr := range libraries(database, members) {
+			sendLoanReminders(client, mgr, cfg)
+		}
+	}
+}
+
+// sendLoanReminders reminds the owners of loans in the shared library,
+// and members of loans in their own
+func sendLoanReminders(client *nostr.Client, mgr *manga.Manager, cfg *config.Config) {
+	recipients, send := cfg.Bot.Owners, client.SendDirectMessage
+	if mgr.User() != "" {
+		recipients, send = []string{mgr.User()}, client.SendPrivateMessage
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	reminders, err := mgr.PendingLoanReminders(time.Now(), cfg.Bot.LoanReminderDays)
+	if err != nil {
+		log.Printf("Failed to check loans: %v", err)
+		return
+	}
+
+	for _, r := range reminders {
+		message := formatLoanReminder(r, time.Now().Format("2006-01-02"))
+		sent := false
+		for _, to := range recipients {
+			if err := send(to, message); err != nil {
+				log.Printf("Failed to send loan reminder to %s: %v", to, err)
+				continue
+			}
+			sent = true
+		}
+		// Leave unsent reminders pending so they are retried next time
+		if !sent {
+			continue
+		}
+		if err := mgr.MarkLoanReminded(r.Loan.ID, r.Kind); err != nil {
+			log.Printf("Failed to record loan reminder: %v", err)
+			continue
+		}
+		log.Printf("Sent %s reminder for loan %s (%s)", r.Kind, r.Loan.ID, r.Loan.Title)
+	}
+}
+
+func formatLoanReminder(r manga.LoanReminder, today string) string {
+	l := r.Loan
+	subject, who := "返却期限", "📍 借りた先: "+l.Counterparty
+	if l.Direction == manga.LoanLent {
+		subject, who = "貸出の返却期限", "👤 貸した相手: "+l.Counterparty
+	}
+
+	var head string
+	switch {
+	case l.Overdue(today):
+		head = "⚠️ " + subject + "を過ぎています"
+	case r.Kind == manga.ReminderDue:
+		head = "📕 今日が" + subject + "です"
+	default:
+		head = "📗 " + subject + "が近づいています"
+	}
+
+	return fmt.Sprintf("%s\n\n"+
+		"%s\n"+
+		"%s\n"+
+		"📅 期限: %s",
+		head, l.Title, who, l.Due)
+}
+
+func runScheduledGC(database *db.DB, cfg *config.Config) {
+	interval, err := time.ParseDuration(cfg.Database.GCInterval)
+	if err != nil {
+		log.Printf("Invalid GC interval: %v, using 6 hours", err)
+		interval = 6 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		res, err := database.RunGCLoop(cfg.Database.GCDiscardRatio)
+		if err != nil {
+			log.Printf("Database GC failed: %v", err)
+			continue
+		}
+		log.Printf("Database GC: %d rewrite(s), disk usage %s -> %s",
+			res.Rewrites, db.FormatSize(res.DiskBefore), db.FormatSize(res.DiskAfter))
+	}
+}
+
+func runScheduledBackups(database *db.DB, cfg *config.Config) {
+	interval, err := time.ParseDuration(cfg.Backup.Interval)
+	if err != nil {
+		log.Printf("Invalid backup interval: %v, using 24 hours", err)
+		interval = 24 * time.Hour
+	}
+
+	// Initial snapshot on startup
+	writeSnapshot(database, cfg.Backup.Dir, cfg.Backup.Keep)
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		checkAndAnnounceNewReleases(client, database, cfg.Rakuten.ApplicationID)
+		writeSnapshot(database, cfg.Backup.Dir, cfg.Backup.Keep)
+	}
+}
+
+func writeSnapshot(database *db.DB, dir string, keep int) {
+	path, err := database.WriteSnapshot(dir)
+	if err != nil {
+		log.Printf("Failed to write snapshot: %v", err)
+		return
+	}
+	log.Printf("Wrote snapshot: %s", path)
+
+	removed, err := db.PruneSnapshots(dir, keep)
+	if err != nil {
+		log.Printf("Failed to prune snapshots: %v", err)
+	}
+	for _, p := range removed {
+		log.Printf("Removed old snapshot: %s", p)
+	}
+}
+
+func runRelaySync(client *nostr.Client, database *db.DB, cfg *config.Config) {
+	interval, err := time.ParseDuration(cfg.Sync.Interval)
+	if err != nil {
+		log.Printf("Invalid sync interval: %v, using 15 minutes", err)
+		interval = 15 * time.Minute
+	}
+
+	syncer := relaysync.New(database, client)
+	syncOnce := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		report, err := syncer.Sync(ctx)
+		if err != nil {
+			log.Printf("Relay sync failed: %v", err)
+			return
+		}
+		if report.Changed > 0 || report.Pulled > 0 || report.Published > 0 {
+			log.Printf("Relay sync: %d local changes, %d records pulled, %d buckets published",
+				report.Changed, report.Pulled, report.Published)
+		}
+	}
+
+	// Initial sync on startup
+	syncOnce()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		syncOnce()
+	}
+}
+
+// listSyncDelay batches library changes, e.g. a bulk import, into one
+// update of the lists
+const listSyncDelay = time.Minute
+
+// runListSync publishes each library's NIP-51 lists at startup and again
+// shortly after its collection or mutes change
+func runListSync(client *nostr.Client, database *db.DB, members []string) {
+	var (
+		mu    sync.Mutex
+		dirty = make(map[string]bool) // Owner public keys, "" for the shared library
+		wake  = make(chan struct{}, 1)
+	)
+	database.OnChange(func(keys []string) {
+		changed := manga.ChangedLibraries(keys)
+		if len(changed) == 0 {
+			return
+		}
+		mu.Lock()
+		for _, user := range changed {
+			if user == "" || slices.Contains(members, user) {
+				dirty[user] = true
+			}
+		}
+		mu.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+
+	for _, mgr := range libraries(database, members) {
+		publishLists(client, mgr)
+	}
+
+	for range wake {
+		time.Sleep(listSyncDelay)
+		mu.Lock()
+		users := dirty
+		dirty = make(map[string]bool)
+		mu.Unlock()
+
+		shared := manga.NewManager(database)
+		for user := range users {
+			publishLists(client, shared.ForUser(user))
+		}
+	}
+}
+
+// publishLists publishes a library's owned, wishlist and series lists
+func publishLists(client *nostr.Client, mgr *manga.Manager) {
+	lists, err := mgr.BookLists()
+	if err != nil {
+		log.Printf("Failed to build lists: %v", err)
+		return
+	}
+	for _, l := range lists {
+		list := nostr.List{
+			ID:    "komikan-" + l.Name,
+			Title: l.Title,
+			Owner: mgr.User(),
+		}
+		if mgr.User() != "" {
+			list.ID += "-" + mgr.User()
+		}
+		for _, item := range l.Items {
+			list.Items = append(list.Items, nostr.ListItem{ISBN: item.ISBN, URL: item.URL})
+		}
+		if err := client.PublishList(list); err != nil {
+			log.Printf("Failed to publish %s list: %v", list.ID, err)
+		}
 	}
 }
 
-func checkAndAnnounceNewReleases(client *nostr.Client, database *db.DB, rakutenAPIKey string) {
+func checkAndAnnounceNewReleases(client *nostr.Client, database *db.DB, covers *cover.Cache, rakutenAPIKey string, members []string) {
 	log.Println("Checking for new releases...")
 
 	mgr := manga.NewManager(database)
-	newReleases, err := mgr.CheckNewReleases(rakutenAPIKey)
+	newReleases, err := mgr.CheckNewReleases(rakutenAPIKey, members)
 	if err != nil {
 		log.Printf("Failed to check for new releases: %v", err)
 		return
@@ -139,7 +626,33 @@ func checkAndAnnounceNewReleases(client *nostr.Client, database *db.DB, rakutenA
 	// Announce each new release
 	for _, release := range newReleases {
 		message := formatNewReleaseMessage(release)
-		if err := client.Publish(message); err != nil {
+		if len(release.Subscribers) > 0 {
+			sendToSubscribers(client, mgr, release, message)
+		}
+		for _, member := range release.Members {
+			if err := client.SendPrivateMessage(member, message); err != nil {
+				log.Printf("Failed to send release to member %s: %v", member, err)
+				continue
+			}
+			if err := mgr.ForUser(member).MarkReleaseNotified(release.SeriesTitle, release.NewVolume); err != nil {
+				log.Printf("Failed to record release notification: %v", err)
+			}
+		}
+		if !release.Announce {
+			continue
+		}
+
+		var images []nostr.Image
+		if covers != nil && release.CoverURL != "" {
+			img, err := coverImage(client, covers, release)
+			if err != nil {
+				log.Printf("Warning: announcing without cover: %v", err)
+			} else {
+				images = append(images, img)
+			}
+		}
+
+		if err := client.PublishWithImages(message, images); err != nil {
 			log.Printf("Failed to publish announcement: %v", err)
 		} else {
 			log.Printf("Announced: %s Vol.%d", release.SeriesTitle, release.NewVolume)
@@ -147,6 +660,93 @@ func checkAndAnnounceNewReleases(client *nostr.Client, database *db.DB, rakutenA
 	}
 }
 
+// sendToSubscribers delivers a release to its subscribers as NIP-17
+// private messages. The release is marked as sent once anyone received
+// it, so a relay outage is retried on the next check.
+func sendToSubscribers(client *nostr.Client, mgr *manga.Manager, release manga.NewReleaseCheckResult, message string) {
+	sent := 0
+	for _, pubkey := range release.Subscribers {
+		if err := client.SendPrivateMessage(pubkey, message); err != nil {
+			log.Printf("Failed to send release to %s: %v", pubkey, err)
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return
+	}
+	if err := mgr.MarkReleaseNotified(release.SeriesTitle, release.NewVolume); err != nil {
+		log.Printf("Failed to record release notification: %v", err)
+	}
+	log.Printf("Sent %s Vol.%d to %d subscriber(s)", release.SeriesTitle, release.NewVolume, sent)
+}
+
+func checkAndAnnounceAuthorReleases(client *nostr.Client, database *db.DB, cfg *config.Config, members []string) {
+	mgr := manga.NewManager(database)
+	releases, err := mgr.CheckAuthorReleases(cfg.Rakuten.ApplicationID, members)
+	if err != nil {
+		log.Printf("Failed to check followed authors: %v", err)
+	}
+
+	for _, r := range releases {
+		if r.Member != "" {
+			notifyLibrary(client, cfg, r.Member, formatAuthorReleaseMessage(r))
+			continue
+		}
+		if err := client.Publish(formatAuthorReleaseMessage(r)); err != nil {
+			log.Printf("Failed to publish announcement: %v", err)
+		} else {
+			log.Printf("Announced: %s (%s)", r.Book.Title, r.Author)
+		}
+	}
+}
+
+func formatAuthorReleaseMessage(r manga.AuthorRelease) string {
+	return fmt.Sprintf("👤 フォロー中の作者の新刊！\n\n"+
+		"%s\n"+
+		"📅 発売日: %s\n"+
+		"👨‍🎨 作者: %s\n"+
+		"🔗 %s",
+		r.Book.Title,
+		r.Book.SalesDate,
+		r.Book.Author,
+		r.Book.ItemURL)
+}
+
+// coverImage caches a release's cover and describes it for imeta
+// With a media server the cover is uploaded there, otherwise the
+// Rakuten image URL is linked
+func coverImage(client *nostr.Client, covers *cover.Cache, release manga.NewReleaseCheckResult) (nostr.Image, error) {
+	src := cover.OriginalURL(release.CoverURL)
+	entry, err := covers.Download(src)
+	if err != nil {
+		return nostr.Image{}, err
+	}
+
+	img := nostr.Image{
+		URL:      src,
+		MimeType: entry.MimeType,
+		SHA256:   entry.Hash,
+		Size:     entry.Size,
+		Width:    entry.Width,
+		Height:   entry.Height,
+		Alt:      fmt.Sprintf("%s %d巻 表紙", release.SeriesTitle, release.NewVolume),
+	}
+	if img.Blurhash, err = covers.Blurhash(entry.Hash); err != nil {
+		log.Printf("Warning: no blurhash for cover: %v", err)
+	}
+
+	if client.HasMediaServer() {
+		uploaded, err := client.UploadImage(entry.Path, img)
+		if err != nil {
+			log.Printf("Warning: cover upload failed, linking Rakuten image: %v", err)
+			return img, nil
+		}
+		img = uploaded
+	}
+	return img, nil
+}
+
 func formatNewReleaseMessage(release manga.NewReleaseCheckResult) string {
 	return fmt.Sprintf("📖 新刊情報！\n\n"+
 		"%s Vol.%d が発売予定です！\n"+
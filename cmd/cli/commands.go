@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a CLI subcommand
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+// commands maps subcommand names to their handlers
+var commands = map[string]command{
+	"list":     {"List manga filtered by author, publisher, tag, series or date", runList},
+	"search":   {"Full-text search over titles, authors, tags and notes", runSearch},
+	"read":     {"Record reading progress: start, finish, drop, rate, note, show", runRead},
+	"progress": {"Show reading progress per series", runProgress},
+	"tsundoku": {"List owned but unread volumes (積読), oldest first", runTsundoku},
+	"loans":    {"Track borrowed and lent volumes with due dates", runLoans},
+	"purchase": {"Record what was paid: price, points, store, physical/digital", runPurchase},
+	"prices":   {"Show wishlist price and stock history, optionally checking Rakuten now", runPrices},
+	"stats":    {"Reports: spend by month, series, publisher or store", runStats},
+	"covers":   {"Download covers into a local cache and make thumbnails", runCovers},
+	"import":   {"Bulk import from CSV, JSON, ブクログ, 読書メーター or Calibre", runImport},
+	"export":   {"Export the whole library as JSON Lines or CSV", runExport},
+	"restore":  {"Restore a JSON Lines export into a database", runRestore},
+	"db":       {"Database maintenance: backup, restore, verify, migrate, stats, gc", runDB},
+	"outbox":   {"Show Nostr events waiting to be accepted by enough relays", runOutbox},
+	"sync":     {"Sync the library with Nostr relays, or rebuild an empty database from them", runSync},
+}
+
+// runCommand dispatches to a subcommand
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\nCommands:\n", name)
+		printCommands()
+		os.Exit(1)
+	}
+	cmd.run(args)
+}
+
+// printCommands prints the available subcommands
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].summary)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/cover"
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runCovers(args []string) {
+	fs := flag.NewFlagSet("covers", flag.ExitOnError)
+	var (
+		dir    = fs.String("dir", "data/covers", "Cover cache directory")
+		maxMB  = fs.Int64("max-mb", 200, "Cache size limit in MiB (0 for no limit)")
+		size   = fs.Int("size", cover.DefaultThumbnailSize, "Thumbnail size in pixels")
+		appID  = fs.String("app-id", "", "Rakuten Application ID, to look up missing cover URLs (or set RAKUTEN_APP_ID env var)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli covers [flags] <action> [isbn]")
+		fmt.Fprintln(os.Stderr, "\nActions:")
+		fmt.Fprintln(os.Stderr, "  fetch [isbn]    Download covers into the cache (all manga if no ISBN)")
+		fmt.Fprintln(os.Stderr, "  show  <isbn>    Show the cached cover of a manga")
+		fmt.Fprintln(os.Stderr, "  thumb <isbn>    Generate a thumbnail and print its path")
+		fmt.Fprintln(os.Stderr, "  stats           Show cache usage")
+		fmt.Fprintln(os.Stderr, "  prune           Remove least recently used covers over the limit")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	action, isbn := fs.Arg(0), fs.Arg(1)
+
+	cache, err := cover.NewCache(*dir, *maxMB<<20)
+	if err != nil {
+		log.Fatalf("Failed to open cover cache: %v", err)
+	}
+
+	switch action {
+	case "stats":
+		files, bytes, err := cache.Usage()
+		if err != nil {
+			log.Fatalf("Failed to read cover cache: %v", err)
+		}
+		fmt.Printf("%d file(s), %s of %s\n", files, db.FormatSize(bytes), db.FormatSize(*maxMB<<20))
+		return
+	case "prune":
+		removed, freed, err := cache.Prune()
+		if err != nil {
+			log.Fatalf("Failed to prune cover cache: %v", err)
+		}
+		fmt.Printf("Removed %d file(s), freed %s\n", removed, db.FormatSize(freed))
+		return
+	}
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	switch action {
+	case "fetch":
+		var provider api.Provider
+		if id := getRakutenAppID(*appID); id != "" {
+			provider = api.NewRakutenClient(id)
+		}
+		fetchCovers(mgr, cache, provider, isbn)
+	case "show", "thumb":
+		if isbn == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		entry, err := mgr.CacheCover(cache, isbn)
+		if err != nil {
+			log.Fatalf("Failed to get cover: %v", err)
+		}
+		if action == "show" {
+			fmt.Printf("%s\n  %dx%d %s, %s\n", entry.Path, entry.Width, entry.Height, entry.MimeType, db.FormatSize(entry.Size))
+			return
+		}
+		path, err := cache.Thumbnail(entry.Hash, *size)
+		if err != nil {
+			log.Fatalf("Failed to create thumbnail: %v", err)
+		}
+		fmt.Println(path)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// fetchCovers caches the covers of one or all manga
+// With a provider, manga without cover URLs are looked up first
+func fetchCovers(mgr *manga.Manager, cache *cover.Cache, provider api.Provider, isbn string) {
+	var books []manga.Manga
+	if isbn != "" {
+		mg, err := mgr.GetByISBN(isbn)
+		if err != nil {
+			log.Fatalf("Failed to get manga: %v", err)
+		}
+		books = []manga.Manga{*mg}
+	} else {
+		var err error
+		if books, err = mgr.List(); err != nil {
+			log.Fatalf("Failed to list manga: %v", err)
+		}
+	}
+
+	fetched, missing, failed := 0, 0, 0
+	for _, b := range books {
+		if b.CoverURL() == "" && provider != nil {
+			if _, err := mgr.RefreshCoverURLs(provider, b.ISBN); err != nil {
+				fmt.Printf("! %s: %v\n", b.ISBN, err)
+			}
+			// Respect the Rakuten API rate limit
+			time.Sleep(time.Second)
+		}
+
+		entry, err := mgr.CacheCover(cache, b.ISBN)
+		switch {
+		case errors.Is(err, manga.ErrNoCover):
+			missing++
+		case err != nil:
+			fmt.Printf("! %s: %v\n", b.ISBN, err)
+			failed++
+		default:
+			fmt.Printf("- %s: %dx%d %s\n", b.Title, entry.Width, entry.Height, entry.Hash[:12])
+			fetched++
+		}
+	}
+
+	fmt.Printf("\n%d cached, %d without cover URL, %d failed\n", fetched, missing, failed)
+	if missing > 0 && provider == nil {
+		fmt.Println("Set RAKUTEN_APP_ID to look up missing cover URLs.")
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// dbCommands maps "komikan-cli db <name>" subcommands to their handlers
+var dbCommands = map[string]command{
+	"backup":  {"Write a full or incremental Badger backup", runDBBackup},
+	"restore": {"Load backups or snapshots into a database", runDBRestore},
+	"verify":  {"Check that a backup or snapshot file can be loaded", runDBVerify},
+	"migrate": {"Apply pending schema migrations", runDBMigrate},
+	"stats":   {"Show database size and key counts", runDBStats},
+	"gc":      {"Run value log GC until nothing is left to reclaim", runDBGC},
+	"reindex": {"Rebuild all secondary indexes from the records", runDBReindex},
+
+	"migrate-backend": {"Copy all data to another storage driver (badger, sqlite)", runDBMigrateBackend},
+}
+
+func runDB(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli db <command> [flags]\n\nCommands:")
+		printDBCommands()
+		os.Exit(1)
+	}
+
+	cmd, ok := dbCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown db command: %s\n\nCommands:\n", args[0])
+		printDBCommands()
+		os.Exit(1)
+	}
+	cmd.run(args[1:])
+}
+
+func printDBCommands() {
+	names := make([]string, 0, len(dbCommands))
+	for name := range dbCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, dbCommands[name].summary)
+	}
+}
+
+func runDBBackup(args []string) {
+	fs := flag.NewFlagSet("db backup", flag.ExitOnError)
+	var (
+		output = fs.String("o", "", "Output file (default: stdout)")
+		since  = fs.Uint64("since", 0, "Only include changes after this version (incremental backup)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	next, err := database.Backup(w, *since)
+	if err != nil {
+		log.Fatalf("Backup failed: %v", err)
+	}
+
+	// Keep stdout clean for piping
+	fmt.Fprintf(os.Stderr, "Backup complete. Use -since %d for the next incremental backup.\n", next)
+}
+
+func runDBRestore(args []string) {
+	fs := flag.NewFlagSet("db restore", flag.ExitOnError)
+	var (
+		merge  = fs.Bool("merge", false, "Allow loading into a non-empty database")
+		dbPath = fs.String("db", "data/komikan.db", "Database path to restore into")
+		driver = fs.String("driver", "", "Database driver: badger or sqlite (default: detect from path)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli db restore [flags] <full.bak> [incremental.bak...]")
+		fmt.Fprintln(os.Stderr, "\nBadger backups load into a Badger database and SQLite snapshots into a\nSQLite one; use db migrate-backend to change drivers.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Check every file before touching the target, so a mismatch does not
+	// leave a partial restore behind
+	target := *driver
+	if target == "" {
+		target = db.DetectDriver(*dbPath)
+	}
+	for _, path := range fs.Args() {
+		written, err := db.BackupDriver(path)
+		if err != nil {
+			log.Fatalf("Failed to open backup: %v", err)
+		}
+		if written != target {
+			log.Fatalf("%s is a %s backup but %s is a %s database. Restore it into a %s database (see -db and -driver)", path, written, *dbPath, target, written)
+		}
+	}
+
+	// Backups carry their own schema version; migrate after loading
+	database, err := db.NewDB(db.Config{Path: *dbPath, Driver: target, SkipMigrations: true})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	empty, err := database.IsEmpty()
+	if err != nil {
+		log.Fatalf("Failed to inspect database: %v", err)
+	}
+	if !empty && !*merge {
+		log.Fatal("Target database is not empty. Restore into a fresh -db path or use -merge")
+	}
+
+	// Backups are applied in the order given: full first, then incrementals
+	for _, path := range fs.Args() {
+		if err := database.LoadFile(path); err != nil {
+			log.Fatalf("Failed to restore %s: %v", path, err)
+		}
+		fmt.Printf("Loaded: %s\n", path)
+	}
+
+	report, err := database.Migrate(db.MigrateOptions{})
+	if err != nil {
+		log.Fatalf("Failed to migrate restored data: %v", err)
+	}
+	printMigrationReport(report)
+}
+
+func runDBVerify(args []string) {
+	fs := flag.NewFlagSet("db verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli db verify <backup.bak>...")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		driver, keys, err := db.VerifyBackupFile(path)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("OK   %s (%s, %d keys)\n", path, driver, keys)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runDBMigrate(args []string) {
+	fs := flag.NewFlagSet("db migrate", flag.ExitOnError)
+	var (
+		dryRun    = fs.Bool("dry-run", false, "Run migrations without committing them")
+		backupDir = fs.String("backup-dir", "", "Snapshot directory before migrating (default: pre-migrate next to -db)")
+		dbPath    = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath, SkipMigrations: true})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	dir := *backupDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(*dbPath), "pre-migrate")
+	}
+
+	report, err := database.Migrate(db.MigrateOptions{DryRun: *dryRun, BackupDir: dir})
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	printMigrationReport(report)
+	if *dryRun {
+		fmt.Println("Dry run: no changes were committed.")
+	}
+}
+
+func printMigrationReport(report *db.MigrationReport) {
+	if report.Backup != "" {
+		fmt.Printf("Pre-migration snapshot: %s\n", report.Backup)
+	}
+	for _, m := range report.Applied {
+		fmt.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+	}
+	fmt.Printf("Schema version: %d -> %d\n", report.From, report.To)
+}
+
+func runDBMigrateBackend(args []string) {
+	fs := flag.NewFlagSet("db migrate-backend", flag.ExitOnError)
+	var (
+		from       = fs.String("from", "data/komikan.db", "Source database path")
+		fromDriver = fs.String("from-driver", "", "Source driver: badger or sqlite (default: detect)")
+		to         = fs.String("to", "", "Target database path (must be new or empty)")
+		toDriver   = fs.String("to-driver", "", "Target driver: badger or sqlite (default: detect from path)")
+	)
+	fs.Parse(args)
+
+	if *to == "" {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli db migrate-backend -from data/komikan.db -to data/komikan.sqlite")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	// Bring the source up to date, then copy it verbatim
+	src, err := db.NewDB(db.Config{Path: *from, Driver: *fromDriver})
+	if err != nil {
+		log.Fatalf("Failed to open source database: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := db.NewDB(db.Config{Path: *to, Driver: *toDriver, SkipMigrations: true})
+	if err != nil {
+		log.Fatalf("Failed to open target database: %v", err)
+	}
+	defer dst.Close()
+
+	n, err := db.CopyAll(dst, src)
+	if err != nil {
+		log.Fatalf("Backend migration failed: %v", err)
+	}
+
+	fmt.Printf("Copied %d key(s) from %s (%s) to %s (%s)\n", n, *from, src.Driver(), *to, dst.Driver())
+	fmt.Println("Set database.driver and database.path in config.yaml to use the new database.")
+}
+
+func runDBStats(args []string) {
+	fs := flag.NewFlagSet("db stats", flag.ExitOnError)
+	dbPath := fs.String("db", "data/komikan.db", "Database path")
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	st, err := database.Stats()
+	if err != nil {
+		log.Fatalf("Failed to collect stats: %v", err)
+	}
+
+	fmt.Printf("Path:           %s\n", st.Path)
+	fmt.Printf("Driver:         %s\n", st.Driver)
+	fmt.Printf("Schema version: %d\n", st.Schema)
+	fmt.Printf("Disk usage:     %s\n", db.FormatSize(st.DiskUsage))
+	if st.Driver == db.DriverBadger {
+		fmt.Printf("LSM size:       %s\n", db.FormatSize(st.LSMSize))
+		fmt.Printf("Value log size: %s\n", db.FormatSize(st.VLogSize))
+	}
+	fmt.Printf("Keys:           %d\n", st.Keys)
+	for _, ks := range st.Keyspaces {
+		fmt.Printf("  %-12s %8d keys  %s\n", ks.Name, ks.Keys, db.FormatSize(ks.Bytes))
+	}
+}
+
+func runDBGC(args []string) {
+	fs := flag.NewFlagSet("db gc", flag.ExitOnError)
+	var (
+		ratio  = fs.Float64("discard-ratio", 0.5, "Rewrite value log files with at least this fraction of stale data")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	res, err := database.RunGCLoop(*ratio)
+	if err != nil {
+		log.Fatalf("GC failed: %v", err)
+	}
+	fmt.Printf("Rewrote %d value log file(s). Disk usage: %s -> %s\n",
+		res.Rewrites, db.FormatSize(res.DiskBefore), db.FormatSize(res.DiskAfter))
+}
+
+func runDBReindex(args []string) {
+	fs := flag.NewFlagSet("db reindex", flag.ExitOnError)
+	dbPath := fs.String("db", "data/komikan.db", "Database path")
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.RebuildIndexes(); err != nil {
+		log.Fatalf("Reindex failed: %v", err)
+	}
+	fmt.Println("Indexes rebuilt.")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/export"
+)
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		format = fs.String("format", "jsonl", "Output format: jsonl (full, restorable) or csv (manga only)")
+		output = fs.String("o", "", "Output file (default: stdout)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli export [flags]")
+		fmt.Fprintln(os.Stderr, "\nThe CSV export lists manga records only; reading logs, loans, purchases\nand price history are only in the JSON Lines export.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var n int
+	switch *format {
+	case "jsonl":
+		var report *export.ExportReport
+		report, err = export.WriteJSONL(w, database)
+		if report != nil {
+			n = report.Records
+			for _, key := range report.Skipped {
+				fmt.Fprintf(os.Stderr, "Warning: skipped %s (value is not JSON)\n", key)
+			}
+		}
+	case "csv":
+		n, err = export.WriteCSV(w, newManager(database))
+	default:
+		log.Fatalf("Unknown export format: %s", *format)
+	}
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	// Keep stdout clean for piping
+	fmt.Fprintf(os.Stderr, "Exported %d record(s)\n", n)
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	var (
+		dryRun = fs.Bool("dry-run", false, "Validate the export without writing")
+		dbPath = fs.String("db", "data/komikan.db", "Empty database path to restore into")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli restore [flags] <export.jsonl>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open export: %v", err)
+	}
+	defer f.Close()
+
+	// Restore migrates after loading, based on the export's schema version
+	database, err := db.NewDB(db.Config{Path: *dbPath, SkipMigrations: true})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	report, err := export.Restore(f, database, export.RestoreOptions{DryRun: *dryRun})
+	if report != nil {
+		fmt.Printf("Export from %s (schema v%d)\n", report.Header.ExportedAt.Format("2006-01-02 15:04:05"), report.Header.SchemaVersion)
+		fmt.Printf("Written: %d of %d\n", report.Written, report.Header.Records)
+	}
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+	if *dryRun {
+		fmt.Println("Dry run: nothing was written.")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/importer"
+)
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		format   = fs.String("format", "csv", "Input format: "+strings.Join(importer.Formats(), ", "))
+		mapping  = fs.String("map", "", "Column mapping for csv/json, e.g. \"isbn=ISBN,title=書名,author=2\"")
+		encoding = fs.String("encoding", "", "Input encoding: utf8 or sjis (default: auto-detect)")
+		apply    = fs.Bool("apply", false, "Register matched rows (default is a dry run)")
+		interval = fs.Duration("interval", time.Second, "Delay between Rakuten API requests")
+		dbPath   = fs.String("db", "data/komikan.db", "Database path")
+		appID    = fs.String("app-id", "", "Rakuten Application ID (or set RAKUTEN_APP_ID env var)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli import [flags] <file or Calibre library dir>")
+		fmt.Fprintln(os.Stderr, "\nCalibre libraries are read from metadata.db (or the metadata.opf files\nwhen there is none); a metadata.db or metadata.opf file may also be given.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	id := getRakutenAppID(*appID)
+	if id == "" {
+		log.Fatal("Rakuten Application ID is required. Use -app-id flag or set RAKUTEN_APP_ID env var")
+	}
+
+	colMapping, err := importer.ParseMapping(*mapping)
+	if err != nil {
+		log.Fatalf("Invalid mapping: %v", err)
+	}
+
+	rows, err := importer.ReadFile(fs.Arg(0), *format, importer.Options{
+		Mapping:  colMapping,
+		Encoding: *encoding,
+	})
+	if err != nil {
+		log.Fatalf("Failed to read import file: %v", err)
+	}
+	fmt.Printf("Read %d row(s) from %s\n", len(rows), fs.Arg(0))
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	resolver := importer.NewResolver(api.NewRakutenClient(id))
+	resolver.Interval = *interval
+	report := resolver.Resolve(rows, mgr)
+
+	printImportReport(report)
+
+	if !*apply {
+		fmt.Println("\nDry run: nothing was registered. Re-run with -apply to add matched rows.")
+		return
+	}
+
+	added, err := importer.Apply(report, mgr)
+	if err != nil {
+		log.Fatalf("Import stopped after %d addition(s): %v", added, err)
+	}
+	fmt.Printf("\nAdded %d manga.\n", added)
+}
+
+func printImportReport(report *importer.Report) {
+	fmt.Println("\nImport Report:")
+	fmt.Println("==================")
+	for _, res := range report.Results {
+		label := fmt.Sprintf("line %d", res.Row.Line)
+		switch res.Status {
+		case importer.StatusMatched:
+			note := ""
+			if res.Existing {
+				note = " (already registered)"
+			}
+			fmt.Printf("[matched]   %s: %s - %s%s\n", label, res.Manga.Title, res.Manga.ISBN, note)
+		case importer.StatusAmbiguous:
+			fmt.Printf("[ambiguous] %s: %s (%s)\n", label, describeRow(res.Row), res.Reason)
+			for _, c := range res.Candidates {
+				fmt.Printf("              - %s (%s) - %s\n", c.Title, c.Author, c.Isbn)
+			}
+		case importer.StatusFailed:
+			fmt.Printf("[failed]    %s: %s (%s)\n", label, describeRow(res.Row), res.Reason)
+		}
+	}
+
+	fmt.Printf("\nMatched: %d, Ambiguous: %d, Failed: %d\n",
+		report.Count(importer.StatusMatched),
+		report.Count(importer.StatusAmbiguous),
+		report.Count(importer.StatusFailed))
+}
+
+func describeRow(row importer.Row) string {
+	switch {
+	case row.Title != "" && row.ISBN != "":
+		return fmt.Sprintf("%s - %s", row.Title, row.ISBN)
+	case row.Title != "":
+		return row.Title
+	default:
+		return row.ISBN
+	}
+}
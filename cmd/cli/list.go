@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var (
+		author    = fs.String("author", "", "Only manga by this author")
+		publisher = fs.String("publisher", "", "Only manga from this publisher")
+		tag       = fs.String("tag", "", "Only manga with this tag")
+		series    = fs.String("series", "", "Only manga in this series")
+		status    = fs.String("status", "", "Only manga with this status: owned, wishlist, preorder")
+		since     = fs.String("since", "", "Only manga published on or after this date (e.g. 2024-01)")
+		sortKey   = fs.String("sort", "isbn", "Sort by: "+strings.Join(manga.SortKeys, ", "))
+		limit     = fs.Int("limit", 0, "Maximum number of results (0 for all)")
+		dbPath    = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+	books, err := mgr.Filter(manga.ListOptions{
+		Author:    *author,
+		Publisher: *publisher,
+		Tag:       *tag,
+		Series:    *series,
+		Status:    *status,
+		Since:     *since,
+		Sort:      *sortKey,
+		Limit:     *limit,
+	})
+	if err != nil {
+		log.Fatalf("Failed to list manga: %v", err)
+	}
+
+	if len(books) == 0 {
+		fmt.Println("No matching manga.")
+		return
+	}
+
+	for _, b := range books {
+		printManga(b)
+	}
+	fmt.Printf("\n%d manga\n", len(books))
+}
+
+// printManga prints one line per manga, as used by the list views
+func printManga(b manga.Manga) {
+	if b.Series != "" {
+		fmt.Printf("- %s Vol.%d [%s] (%s) - %s\n", b.Title, b.Volume, b.Series, b.Author, b.ISBN)
+	} else {
+		fmt.Printf("- %s (%s) - %s\n", b.Title, b.Author, b.ISBN)
+	}
+}
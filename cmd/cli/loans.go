@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runLoans(args []string) {
+	fs := flag.NewFlagSet("loans", flag.ExitOnError)
+	var (
+		isbn   = fs.String("isbn", "", "ISBN of the volume (fills in the title if registered)")
+		from   = fs.String("from", "", "Lender: library, rental shop or friend (borrow)")
+		to     = fs.String("to", "", "Borrower (lend)")
+		due    = fs.String("due", "", "Due date as YYYY-MM-DD")
+		date   = fs.String("date", "", "Date as YYYY-MM-DD (default: today)")
+		notes  = fs.String("notes", "", "Notes")
+		all    = fs.Bool("all", false, "Include returned loans in the list")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli loans [flags] [action] [args]")
+		fmt.Fprintln(os.Stderr, "\nActions:")
+		fmt.Fprintln(os.Stderr, "  list                 List outstanding loans, overdue first (default)")
+		fmt.Fprintln(os.Stderr, "  borrow [title]       Record a borrowed volume (-from, -due)")
+		fmt.Fprintln(os.Stderr, "  lend   [title]       Record a volume lent out (-to, -due)")
+		fmt.Fprintln(os.Stderr, "  return <id>          Mark a loan as returned")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	action := "list"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	value := ""
+	if fs.NArg() > 1 {
+		value = strings.Join(fs.Args()[1:], " ")
+	}
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	switch action {
+	case "list":
+		listLoans(mgr, *all)
+	case "borrow", "lend":
+		loan := manga.Loan{
+			Direction:    manga.LoanBorrowed,
+			ISBN:         *isbn,
+			Title:        value,
+			Counterparty: *from,
+			Started:      *date,
+			Due:          *due,
+			Notes:        *notes,
+		}
+		if action == "lend" {
+			loan.Direction, loan.Counterparty = manga.LoanLent, *to
+		}
+		if loan.Counterparty == "" {
+			log.Fatalf("Specify who with -from (borrow) or -to (lend)")
+		}
+		loan, err = mgr.AddLoan(loan)
+		if err != nil {
+			log.Fatalf("Failed to record loan: %v", err)
+		}
+		fmt.Printf("Recorded loan %s\n", loan.ID)
+		printLoan(loan, time.Now().Format("2006-01-02"))
+	case "return":
+		if value == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		loan, err := mgr.ReturnLoan(value, *date)
+		if err != nil {
+			log.Fatalf("Failed to return loan: %v", err)
+		}
+		fmt.Printf("Returned %s on %s\n", loan.Title, loan.Returned)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+func listLoans(mgr *manga.Manager, all bool) {
+	loans, err := mgr.ListLoans(all)
+	if err != nil {
+		log.Fatalf("Failed to list loans: %v", err)
+	}
+
+	if len(loans) == 0 {
+		fmt.Println("No outstanding loans.")
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	overdue := 0
+	for _, l := range loans {
+		if l.Overdue(today) {
+			overdue++
+		}
+	}
+
+	fmt.Println("Loans:")
+	fmt.Println("======")
+	// Overdue loans first, each group already in due date order
+	for _, l := range loans {
+		if l.Overdue(today) {
+			printLoan(l, today)
+		}
+	}
+	for _, l := range loans {
+		if !l.Overdue(today) {
+			printLoan(l, today)
+		}
+	}
+	fmt.Printf("\n%d loan(s), %d overdue\n", len(loans), overdue)
+}
+
+func printLoan(l manga.Loan, today string) {
+	who := "from " + l.Counterparty
+	if l.Direction == manga.LoanLent {
+		who = "to " + l.Counterparty
+	}
+
+	state := "due " + l.Due
+	switch {
+	case !l.Outstanding():
+		state = "returned " + l.Returned
+	case l.Overdue(today):
+		state = "OVERDUE since " + l.Due
+	case l.Due == today:
+		state = "due TODAY"
+	}
+
+	fmt.Printf("- [%s] %s (%s %s, %s)\n", l.ID, l.Title, l.Direction, who, state)
+}
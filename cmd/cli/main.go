@@ -5,13 +5,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/kench/komikan-go/internal/api"
 	"github.com/kench/komikan-go/internal/db"
 	"github.com/kench/komikan-go/internal/manga"
+	"github.com/kench/komikan-go/internal/nostr"
 )
 
 func main() {
+	// Subcommands (e.g. "komikan-cli import ...") have their own flags
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	var (
 		isbn    = flag.String("isbn", "", "ISBN code to add")
 		list    = flag.Bool("list", false, "List all manga")
@@ -29,7 +37,7 @@ func main() {
 	}
 	defer database.Close()
 
-	mgr := manga.NewManager(database)
+	mgr := newManager(database)
 
 	if *list {
 		// List all manga
@@ -46,11 +54,7 @@ func main() {
 		fmt.Println("Registered Manga:")
 		fmt.Println("==================")
 		for _, b := range books {
-			if b.Series != "" {
-				fmt.Printf("- %s Vol.%d [%s] (%s) - %s\n", b.Title, b.Volume, b.Series, b.Author, b.ISBN)
-			} else {
-				fmt.Printf("- %s (%s) - %s\n", b.Title, b.Author, b.ISBN)
-			}
+			printManga(b)
 		}
 		return
 	}
@@ -123,22 +127,9 @@ func main() {
 			log.Fatalf("Failed to find book: %v", err)
 		}
 
-		m := manga.Manga{
-			ID:          *isbn,
-			Title:       book.Title,
-			Author:      book.Author,
-			Publisher:   book.Publisher,
-			ISBN:        book.Isbn,
-			PublishDate: book.SalesDate,
-			URL:         book.ItemURL,
-		}
-
-		// Extract volume info
-		volInfo := manga.ExtractVolumeInfo(book.Title)
-		if volInfo.HasVolume {
-			m.Volume = volInfo.Volume
-			m.Series = volInfo.Title
-		}
+		// Build entry with volume info extracted from the title
+		m := manga.FromBookInfo(*book)
+		m.ID = *isbn
 
 		if err := mgr.Add(m); err != nil {
 			log.Fatalf("Failed to add manga: %v", err)
@@ -156,8 +147,13 @@ func main() {
 	fmt.Println("  komikan-cli -isbn 9784088818791")
 	fmt.Println("  komikan-cli -list")
 	fmt.Println("  komikan-cli -latest ダンダダン")
+	fmt.Println("  komikan-cli list -author 藤本タツキ -sort date")
+	fmt.Println("  komikan-cli import -format booklog booklog.csv")
+	fmt.Println("\nCommands:")
+	printCommands()
 	fmt.Println("\nEnvironment Variables:")
 	fmt.Println("  RAKUTEN_APP_ID  Rakuten Application ID")
+	fmt.Println("  KOMIKAN_USER    Work on a member's library (npub or hex public key)")
 	os.Exit(1)
 }
 
@@ -167,3 +163,18 @@ func getRakutenAppID(fromFlag string) string {
 	}
 	return os.Getenv("RAKUTEN_APP_ID")
 }
+
+// newManager opens the library selected by KOMIKAN_USER, a member's npub
+// or hex public key, or the shared library when it is unset
+func newManager(database *db.DB) *manga.Manager {
+	mgr := manga.NewManager(database)
+	user := os.Getenv("KOMIKAN_USER")
+	if user == "" {
+		return mgr
+	}
+	pubkey, err := nostr.DecodePublicKey(user)
+	if err != nil {
+		log.Fatalf("Invalid KOMIKAN_USER: %v", err)
+	}
+	return mgr.ForUser(pubkey)
+}
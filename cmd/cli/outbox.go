@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/nostr"
+)
+
+// runOutbox lists Nostr events the bot has not yet published to enough relays
+func runOutbox(args []string) {
+	fs := flag.NewFlagSet("outbox", flag.ExitOnError)
+	dbPath := fs.String("db", "data/komikan.db", "Database path")
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	entries, err := nostr.NewOutbox(database).Pending()
+	if err != nil {
+		log.Fatalf("Failed to read outbox: %v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("Outbox is empty.")
+		return
+	}
+
+	for _, e := range entries {
+		fmt.Printf("%s kind %d, queued %s, %d attempt(s), next %s\n",
+			e.Event.ID, e.Event.Kind, e.CreatedAt.Format(time.DateTime), e.Attempts, e.NextAttempt.Format(time.DateTime))
+		fmt.Printf("  Accepted %d of %d needed\n", len(e.Accepted), e.Quorum)
+		for _, url := range e.Relays {
+			if msg, ok := e.Results[url]; ok {
+				fmt.Printf("  ✗ %s: %s\n", url, msg)
+			} else if slices.Contains(e.Accepted, url) {
+				fmt.Printf("  ✓ %s\n", url)
+			} else {
+				fmt.Printf("  - %s\n", url)
+			}
+		}
+	}
+}
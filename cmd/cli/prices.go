@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runPrices(args []string) {
+	fs := flag.NewFlagSet("prices", flag.ExitOnError)
+	var (
+		check  = fs.Bool("check", false, "Look up current prices of wishlist and preorder volumes first")
+		appID  = fs.String("app-id", "", "Rakuten Application ID (or set RAKUTEN_APP_ID env var)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli prices [flags] [isbn]")
+		fmt.Fprintln(os.Stderr, "\nShows the price and stock history of a volume, or the latest state of every")
+		fmt.Fprintln(os.Stderr, "wishlist and preorder volume.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	if *check {
+		id := getRakutenAppID(*appID)
+		if id == "" {
+			log.Fatal("Rakuten Application ID is required. Use -app-id flag or set RAKUTEN_APP_ID env var")
+		}
+		alerts, err := mgr.CheckPrices(api.NewRakutenClient(id), time.Second)
+		if err != nil {
+			log.Fatalf("Failed to check prices: %v", err)
+		}
+		for _, a := range alerts {
+			fmt.Printf("! %s: %s\n", a.Kind, a.Manga.Title)
+		}
+	}
+
+	if isbn := fs.Arg(0); isbn != "" {
+		h, err := mgr.GetPriceHistory(isbn)
+		if db.IsNotFound(err) {
+			fmt.Println("No price history.")
+			return
+		}
+		if err != nil {
+			log.Fatalf("Failed to get price history: %v", err)
+		}
+		for _, p := range h.Points {
+			printPricePoint(p)
+		}
+		return
+	}
+
+	var targets []manga.Manga
+	for _, status := range []string{manga.StatusWishlist, manga.StatusPreorder} {
+		list, err := mgr.FindByStatus(status)
+		if err != nil {
+			log.Fatalf("Failed to list manga: %v", err)
+		}
+		targets = append(targets, list...)
+	}
+	if len(targets) == 0 {
+		fmt.Println("No wishlist or preorder volumes.")
+		return
+	}
+
+	for _, b := range targets {
+		fmt.Printf("- %s (%s) [%s]\n", b.Title, b.ISBN, b.Status)
+		h, err := mgr.GetPriceHistory(b.ISBN)
+		if err != nil {
+			if !db.IsNotFound(err) {
+				log.Fatalf("Failed to get price history: %v", err)
+			}
+			fmt.Println("    not checked yet")
+			continue
+		}
+		if p, ok := h.Latest(); ok {
+			fmt.Print("  ")
+			printPricePoint(p)
+		}
+	}
+}
+
+func printPricePoint(p manga.PricePoint) {
+	limited := ""
+	if p.Limited {
+		limited = "  限定版"
+	}
+	fmt.Printf("  %s  %d円  points x%d  %s%s\n", p.CheckedAt.Format("2006-01-02 15:04"), p.Price, p.PointRate, api.StockLabel(p.State()), limited)
+}
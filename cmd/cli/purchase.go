@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runPurchase(args []string) {
+	fs := flag.NewFlagSet("purchase", flag.ExitOnError)
+	var (
+		price    = fs.String("price", "", "Price paid including tax (e.g. 528 or 9.99)")
+		points   = fs.String("points", "0", "Point rebate, valued in the purchase currency")
+		currency = fs.String("currency", manga.DefaultCurrency, "Currency code")
+		store    = fs.String("store", "", "Store name")
+		format   = fs.String("format", manga.FormatPhysical, "physical or digital")
+		date     = fs.String("date", "", "Purchase date as YYYY-MM-DD (default: today)")
+		notes    = fs.String("notes", "", "Notes")
+		dbPath   = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli purchase [flags] <action> [args]")
+		fmt.Fprintln(os.Stderr, "\nActions:")
+		fmt.Fprintln(os.Stderr, "  add    <isbn>     Record a purchase (-price required)")
+		fmt.Fprintln(os.Stderr, "  list   [isbn]     List purchases, optionally for one volume")
+		fmt.Fprintln(os.Stderr, "  delete <id>       Delete a purchase record")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	action, value := fs.Arg(0), fs.Arg(1)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	switch action {
+	case "add":
+		if value == "" || *price == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		p := manga.Purchase{
+			ISBN:     value,
+			Currency: *currency,
+			Store:    *store,
+			Format:   *format,
+			Date:     *date,
+			Notes:    *notes,
+		}
+		if p.Price, err = manga.ParsePrice(*price, *currency); err != nil {
+			log.Fatalf("Invalid price: %v", err)
+		}
+		if p.Points, err = manga.ParsePrice(*points, *currency); err != nil {
+			log.Fatalf("Invalid points: %v", err)
+		}
+		p, err = mgr.AddPurchase(p)
+		if err != nil {
+			log.Fatalf("Failed to record purchase: %v", err)
+		}
+		fmt.Printf("Recorded purchase %s\n", p.ID)
+		printPurchase(mgr, p)
+	case "list":
+		purchases, err := mgr.ListPurchases(value)
+		if err != nil {
+			log.Fatalf("Failed to list purchases: %v", err)
+		}
+		if len(purchases) == 0 {
+			fmt.Println("No purchases recorded.")
+			return
+		}
+		for _, p := range purchases {
+			printPurchase(mgr, p)
+		}
+		fmt.Printf("\n%d purchase(s)\n", len(purchases))
+	case "delete":
+		if value == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		if err := mgr.DeletePurchase(value); err != nil {
+			log.Fatalf("Failed to delete purchase: %v", err)
+		}
+		fmt.Printf("Deleted purchase %s\n", value)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+func printPurchase(mgr *manga.Manager, p manga.Purchase) {
+	title := p.ISBN
+	if m, err := mgr.GetByISBN(p.ISBN); err == nil {
+		title = m.Title
+	}
+
+	fmt.Printf("- [%s] %s %s: %s", p.ID, p.Date, title, manga.FormatPrice(p.Price, p.Currency))
+	if p.Points > 0 {
+		fmt.Printf(" (points -%s)", manga.FormatPrice(p.Points, p.Currency))
+	}
+	fmt.Printf(" %s", p.Format)
+	if p.Store != "" {
+		fmt.Printf(" @ %s", p.Store)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+func runRead(args []string) {
+	fs := flag.NewFlagSet("read", flag.ExitOnError)
+	var (
+		date   = fs.String("date", "", "Date as YYYY-MM-DD (default: today)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli read [flags] <action> <isbn> [value]")
+		fmt.Fprintln(os.Stderr, "\nActions:")
+		fmt.Fprintln(os.Stderr, "  start  <isbn>          Start reading (again, for a re-read)")
+		fmt.Fprintln(os.Stderr, "  finish <isbn>          Mark as read")
+		fmt.Fprintln(os.Stderr, "  drop   <isbn>          Mark as dropped")
+		fmt.Fprintln(os.Stderr, "  rate   <isbn> <1-5>    Rate the volume (0 clears)")
+		fmt.Fprintln(os.Stderr, "  note   <isbn> <text>   Set reading notes")
+		fmt.Fprintln(os.Stderr, "  show   <isbn>          Show the reading log")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	action, isbn := fs.Arg(0), fs.Arg(1)
+	value := strings.Join(fs.Args()[2:], " ")
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	mgr := newManager(database)
+
+	var rec manga.ReadingRecord
+	switch action {
+	case "start":
+		rec, err = mgr.StartReading(isbn, *date)
+	case "finish":
+		rec, err = mgr.FinishReading(isbn, *date)
+	case "drop":
+		rec, err = mgr.DropReading(isbn)
+	case "rate":
+		rating, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			log.Fatalf("Invalid rating: %s", value)
+		}
+		rec, err = mgr.RateReading(isbn, rating)
+	case "note":
+		rec, err = mgr.SetReadingNotes(isbn, value)
+	case "show":
+		rec, err = mgr.GetReading(isbn)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Failed to update reading log: %v", err)
+	}
+
+	printReading(mgr, rec)
+}
+
+func printReading(mgr *manga.Manager, rec manga.ReadingRecord) {
+	title := rec.ISBN
+	if m, err := mgr.GetByISBN(rec.ISBN); err == nil {
+		title = fmt.Sprintf("%s (%s)", m.Title, m.ISBN)
+	}
+
+	fmt.Printf("%s\n", title)
+	fmt.Printf("  Status: %s\n", rec.Status)
+	if rec.Rating > 0 {
+		fmt.Printf("  Rating: %s\n", strings.Repeat("★", rec.Rating)+strings.Repeat("☆", 5-rec.Rating))
+	}
+	for i, s := range rec.Sessions {
+		finished := s.Finished
+		if finished == "" {
+			finished = "..."
+		}
+		fmt.Printf("  Read #%d: %s - %s\n", i+1, s.Started, finished)
+	}
+	if rec.Notes != "" {
+		fmt.Printf("  Notes: %s\n", rec.Notes)
+	}
+}
+
+func runProgress(args []string) {
+	fs := flag.NewFlagSet("progress", flag.ExitOnError)
+	var (
+		series = fs.String("series", "", "Only this series")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	progress, err := newManager(database).ReadingProgress(*series)
+	if err != nil {
+		log.Fatalf("Failed to get reading progress: %v", err)
+	}
+
+	if len(progress) == 0 {
+		fmt.Println("No owned series.")
+		return
+	}
+
+	for _, p := range progress {
+		fmt.Printf("- %s: read %d of %d owned", p.Series, p.Read, p.Owned)
+		if p.Reading > 0 {
+			fmt.Printf(", reading %d", p.Reading)
+		}
+		if p.Dropped > 0 {
+			fmt.Printf(", dropped %d", p.Dropped)
+		}
+		fmt.Println()
+	}
+}
+
+func runTsundoku(args []string) {
+	fs := flag.NewFlagSet("tsundoku", flag.ExitOnError)
+	var (
+		limit  = fs.Int("limit", 0, "Maximum number of results (0 for all)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	unread, err := newManager(database).Tsundoku()
+	if err != nil {
+		log.Fatalf("Failed to list unread manga: %v", err)
+	}
+
+	if len(unread) == 0 {
+		fmt.Println("積読なし！ No owned-but-unread volumes.")
+		return
+	}
+
+	total := len(unread)
+	if *limit > 0 && *limit < len(unread) {
+		unread = unread[:*limit]
+	}
+
+	fmt.Println("積読 (oldest first):")
+	fmt.Println("==================")
+	for _, b := range unread {
+		printManga(b)
+	}
+	fmt.Printf("\n%d unread volume(s)\n", total)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	var (
+		limit  = fs.Int("limit", 20, "Maximum number of results (0 for all)")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli search [flags] <query>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	results, err := newManager(database).Search(query, *limit)
+	if err != nil {
+		log.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No results for \"%s\".\n", query)
+		return
+	}
+
+	for _, r := range results {
+		printManga(r.Manga)
+	}
+	fmt.Printf("\n%d result(s) for \"%s\"\n", len(results), query)
+}
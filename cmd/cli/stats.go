@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+// statsCommands maps "komikan-cli stats <name>" subcommands to their handlers
+var statsCommands = map[string]command{
+	"spend": {"Spending by month, series, publisher, store or format", runStatsSpend},
+}
+
+func runStats(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: komikan-cli stats <command> [flags]\n\nCommands:")
+		printStatsCommands()
+		os.Exit(1)
+	}
+
+	cmd, ok := statsCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown stats command: %s\n\nCommands:\n", args[0])
+		printStatsCommands()
+		os.Exit(1)
+	}
+	cmd.run(args[1:])
+}
+
+func printStatsCommands() {
+	names := make([]string, 0, len(statsCommands))
+	for name := range statsCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, statsCommands[name].summary)
+	}
+}
+
+func runStatsSpend(args []string) {
+	fs := flag.NewFlagSet("stats spend", flag.ExitOnError)
+	var (
+		by     = fs.String("by", "month", "Group by: "+strings.Join(manga.SpendGroups, ", "))
+		from   = fs.String("from", "", "Only purchases on or after this date (e.g. 2026-01)")
+		to     = fs.String("to", "", "Only purchases on or before this date (e.g. 2026-12)")
+		format = fs.String("format", "text", "Output format: text or csv")
+		dbPath = fs.String("db", "data/komikan.db", "Database path")
+	)
+	fs.Parse(args)
+
+	database, err := db.NewDB(db.Config{Path: *dbPath})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	rows, err := newManager(database).SpendReport(manga.SpendOptions{
+		By:   *by,
+		From: *from,
+		To:   *to,
+	})
+	if err != nil {
+		log.Fatalf("Failed to build spend report: %v", err)
+	}
+
+	switch *format {
+	case "text":
+		printSpend(*by, rows)
+	case "csv":
+		if err := writeSpendCSV(*by, rows); err != nil {
+			log.Fatalf("Failed to write CSV: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown output format: %s", *format)
+	}
+}
+
+func printSpend(by string, rows []manga.SpendRow) {
+	if len(rows) == 0 {
+		fmt.Println("No purchases in range.")
+		return
+	}
+
+	fmt.Printf("Spending by %s:\n", by)
+	totals := map[string]*manga.SpendRow{}
+	var currencies []string
+	for _, r := range rows {
+		fmt.Printf("- %s: %s (%d vol.)", r.Key, manga.FormatPrice(r.Total, r.Currency), r.Count)
+		if r.Points > 0 {
+			fmt.Printf(", net %s after points", manga.FormatPrice(r.Net(), r.Currency))
+		}
+		fmt.Println()
+
+		t := totals[r.Currency]
+		if t == nil {
+			t = &manga.SpendRow{Currency: r.Currency}
+			totals[r.Currency] = t
+			currencies = append(currencies, r.Currency)
+		}
+		t.Count += r.Count
+		t.Total += r.Total
+		t.Points += r.Points
+	}
+
+	sort.Strings(currencies)
+	fmt.Println()
+	for _, c := range currencies {
+		t := totals[c]
+		fmt.Printf("Total: %s (%d vol.), net %s\n",
+			manga.FormatPrice(t.Total, c), t.Count, manga.FormatPrice(t.Net(), c))
+	}
+}
+
+// writeSpendCSV writes the report with plain numeric amounts for spreadsheets
+func writeSpendCSV(by string, rows []manga.SpendRow) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{by, "currency", "count", "total", "points", "net"})
+	for _, r := range rows {
+		w.Write([]string{
+			r.Key,
+			r.Currency,
+			strconv.Itoa(r.Count),
+			manga.FormatAmount(r.Total, r.Currency),
+			manga.FormatAmount(r.Points, r.Currency),
+			manga.FormatAmount(r.Net(), r.Currency),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kench/komikan-go/internal/config"
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/nostr"
+	"github.com/kench/komikan-go/internal/relaysync"
+)
+
+// runSync syncs the database with the relays, or rebuilds it from them
+// when it is empty, using the bot's key and relays
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	var (
+		configPath = fs.String("config", "config.yaml", "Config file with the Nostr key and relays")
+		dbPath     = fs.String("db", "", "Database path (default: database.path from the config)")
+	)
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.LoadFromEnv()
+	if *dbPath == "" {
+		*dbPath = cfg.Database.Path
+	}
+
+	database, err := db.NewDB(db.Config{Path: *dbPath, Driver: cfg.Database.Driver})
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	signerCtx, cancelSigner := context.WithTimeout(context.Background(), 2*time.Minute)
+	signer, err := nostr.NewSigner(signerCtx, nostr.SignerConfig{
+		SecretKey:           cfg.Nostr.SecretKey,
+		Passphrase:          cfg.Passphrase,
+		Bunker:              cfg.Nostr.Bunker,
+		BunkerClientKeyFile: cfg.Nostr.BunkerClientKeyFile,
+	})
+	cancelSigner()
+	if err != nil {
+		log.Fatalf("Failed to set up signer: %v", err)
+	}
+	client, err := nostr.NewClient(nostr.Config{
+		Signer: signer,
+		Relays: cfg.Nostr.Relays,
+		Outbox: nostr.NewOutbox(database),
+		Quorum: cfg.Nostr.Quorum,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Nostr client: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		log.Fatalf("Failed to connect to relays: %v", err)
+	}
+	defer client.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	report, err := relaysync.New(database, client).Sync(ctx)
+	if err != nil {
+		log.Fatalf("Sync failed: %v", err)
+	}
+
+	fmt.Printf("Local changes:     %d\n", report.Changed)
+	fmt.Printf("Records pulled:    %d\n", report.Pulled)
+	fmt.Printf("Buckets published: %d\n", report.Published)
+}
@@ -1,20 +1,52 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
 
-	"github.com/nbd-wtf/go-nostr"
+	"github.com/kench/komikan-go/internal/nostr"
+	gonostr "github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 func main() {
+	var (
+		encrypt        = flag.Bool("encrypt", false, "Print the secret key only as a NIP-49 ncryptsec")
+		importKey      = flag.Bool("import", false, "Encrypt the key in NOSTR_SECRET_KEY instead of generating one (implies -encrypt)")
+		passphraseFile = flag.String("passphrase-file", "", "Read the passphrase from this file (default: NOSTR_PASSPHRASE or prompt)")
+	)
+	flag.Parse()
+
+	if *importKey {
+		encryptExisting(*passphraseFile)
+		return
+	}
+
 	// Generate new key pair
-	privKey := nostr.GeneratePrivateKey()
-	pubKey, _ := nostr.GetPublicKey(privKey)
+	privKey := gonostr.GeneratePrivateKey()
+	pubKey, _ := gonostr.GetPublicKey(privKey)
+	npub, _ := nip19.EncodePublicKey(pubKey)
 
-	// Encode to nsec and npub
+	if *encrypt {
+		ncryptsec, err := nostr.EncryptKey(privKey, readPassphrase(*passphraseFile), true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("=== Nostr Key Pair Generated (encrypted) ===")
+		fmt.Printf("Public Key (hex): %s\n", pubKey)
+		fmt.Printf("npub (your public address): %s\n", npub)
+		fmt.Println()
+		printConfig(ncryptsec)
+		return
+	}
+
+	// Encode to nsec
 	nsec, _ := nip19.EncodePrivateKey(privKey)
-	npub, _ := nip19.EncodePublicKey(pubKey)
 
 	fmt.Println("=== Nostr Key Pair Generated ===")
 	fmt.Printf("Secret Key (hex): %s\n", privKey)
@@ -27,3 +59,71 @@ func main() {
 	fmt.Printf("  nostr:\n")
 	fmt.Printf("    secret_key: \"%s\"\n", nsec)
 }
+
+// encryptExisting prints the key in NOSTR_SECRET_KEY as an ncryptsec
+func encryptExisting(passphraseFile string) {
+	key := os.Getenv("NOSTR_SECRET_KEY")
+	if key == "" {
+		log.Fatal("Set NOSTR_SECRET_KEY to the nsec or hex key to encrypt")
+	}
+	ncryptsec, err := nostr.EncryptKey(key, readPassphrase(passphraseFile), false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printConfig(ncryptsec)
+	fmt.Println()
+	fmt.Println("Remove the nsec from config.yaml and your shell history.")
+}
+
+func printConfig(ncryptsec string) {
+	fmt.Println("Add this to config.yaml:")
+	fmt.Printf("  nostr:\n")
+	fmt.Printf("    secret_key: \"%s\"\n", ncryptsec)
+	fmt.Println()
+	fmt.Println("The bot reads the passphrase from NOSTR_PASSPHRASE, the nostr-passphrase")
+	fmt.Println("systemd credential or nostr.passphrase_file.")
+}
+
+// readPassphrase reads the passphrase from a file, NOSTR_PASSPHRASE or the
+// terminal, where it is asked twice and not echoed
+func readPassphrase(path string) string {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read passphrase file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n")
+	}
+	if pass, ok := os.LookupEnv("NOSTR_PASSPHRASE"); ok {
+		return pass
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	pass := prompt(in, "Passphrase: ")
+	if prompt(in, "Repeat passphrase: ") != pass {
+		log.Fatal("Passphrases do not match")
+	}
+	return pass
+}
+
+func prompt(in *bufio.Reader, label string) string {
+	fmt.Fprint(os.Stderr, label)
+	// Without stty (or on a pipe) the input is read as it is
+	if stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read passphrase: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
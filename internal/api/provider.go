@@ -0,0 +1,11 @@
+package api
+
+// Provider looks up book information from an external catalogue
+// RakutenClient is the default implementation
+type Provider interface {
+	SearchByISBN(isbn string) (*BookInfo, error)
+	SearchByTitle(title string) ([]BookInfo, error)
+}
+
+// Ensure RakutenClient satisfies Provider
+var _ Provider = (*RakutenClient)(nil)
@@ -22,8 +22,65 @@ type BookInfo struct {
 	Isbn        string `json:"isbn"`
 	SalesDate   string `json:"salesDate"`
 	ItemURL     string `json:"itemUrl"`
+	SmallImage  string `json:"smallImageUrl"`
 	MediumImage string `json:"mediumImageUrl"`
+	LargeImage  string `json:"largeImageUrl"`
 	Volume      string `json:"volume"`
+
+	ItemPrice    int    `json:"itemPrice"`    // Price including tax, in yen
+	ListPrice    int    `json:"listPrice"`    // Publisher list price, 0 if unset
+	Availability string `json:"availability"` // Stock code, "1" is in stock
+	PointRate    int    `json:"pointRate"`    // Point multiplier, 1 outside campaigns
+	LimitedFlag  int    `json:"limitedFlag"`  // 1 for limited editions
+}
+
+// Stock states derived from Rakuten availability codes
+const (
+	StockInStock     = "in_stock"    // 1: 在庫あり
+	StockShipsLater  = "ships_later" // 2, 3: 通常3〜9日程度で発送
+	StockBackorder   = "backorder"   // 4, 6: メーカー取り寄せ / メーカーに在庫確認
+	StockPreorder    = "preorder"    // 5: 予約受付中
+	StockUnavailable = "unavailable" // No code: not sold at the moment
+)
+
+// StockState maps a Rakuten availability code to a stock state
+func StockState(availability string) string {
+	switch availability {
+	case "1":
+		return StockInStock
+	case "2", "3":
+		return StockShipsLater
+	case "4", "6":
+		return StockBackorder
+	case "5":
+		return StockPreorder
+	}
+	return StockUnavailable
+}
+
+// StockLabel returns the Japanese label Rakuten shows for a stock state
+func StockLabel(state string) string {
+	switch state {
+	case StockInStock:
+		return "在庫あり"
+	case StockShipsLater:
+		return "通常3〜9日程度で発送"
+	case StockBackorder:
+		return "お取り寄せ"
+	case StockPreorder:
+		return "予約受付中"
+	}
+	return "販売なし"
+}
+
+// InStock reports whether Rakuten can ship the book right away
+func (b BookInfo) InStock() bool {
+	return StockState(b.Availability) == StockInStock
+}
+
+// Limited reports whether the book is a limited edition
+func (b BookInfo) Limited() bool {
+	return b.LimitedFlag == 1
 }
 
 // RakutenBooksResponse represents the API response
@@ -159,3 +216,41 @@ func (r *RakutenClient) SearchByTitleSorted(title string, sort string, hits int)
 
 	return books, nil
 }
+
+// SearchByAuthor searches for books by an author, newest first
+func (r *RakutenClient) SearchByAuthor(author string, hits int) ([]BookInfo, error) {
+	baseURL := "https://app.rakuten.co.jp/services/api/BooksBook/Search/20170404"
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("applicationId", r.ApplicationID)
+	q.Set("author", author)
+	q.Set("formatVersion", "2")
+	q.Set("sort", "-releaseDate")
+	if hits > 0 {
+		q.Set("hits", fmt.Sprintf("%d", hits))
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := r.HTTPClient.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result RakutenBooksResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.Items, nil
+}
@@ -0,0 +1,474 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/importer"
+	"github.com/kench/komikan-go/internal/manga"
+	"github.com/kench/komikan-go/internal/nostr"
+)
+
+// Command is a parsed bot command
+type Command struct {
+	Name string
+	Arg  string
+}
+
+// Parse splits a message into a lowercase command name and its argument
+// A leading "/" and full-width spaces are accepted, as phone keyboards
+// often produce them
+func Parse(text string) (Command, bool) {
+	text = strings.ReplaceAll(text, "　", " ")
+	text = strings.TrimPrefix(strings.TrimSpace(text), "/")
+	if text == "" {
+		return Command{}, false
+	}
+	name, arg, _ := strings.Cut(text, " ")
+	return Command{Name: strings.ToLower(name), Arg: strings.TrimSpace(arg)}, true
+}
+
+// spec describes a command
+type spec struct {
+	usage   string
+	summary string
+	library bool // Works on the sender's library: owners and members only
+	run     func(h *Handler, from, arg string) (string, error)
+}
+
+// commands maps command names to their handlers
+// It is filled in by init because help refers back to it
+var commands map[string]spec
+
+func init() {
+	commands = map[string]spec{
+		"help":     {"help", "コマンド一覧", false, (*Handler).help},
+		"add":      {"add <タイトル|ISBN>", "マンガを登録", true, (*Handler).add},
+		"list":     {"list", "シリーズ一覧", true, (*Handler).list},
+		"gaps":     {"gaps", "抜けている巻", true, (*Handler).gaps},
+		"follow":   {"follow [作者]", "作者の新刊を通知（引数なしで一覧）", true, (*Handler).follow},
+		"unfollow": {"unfollow <作者>", "作者のフォローを解除", true, (*Handler).unfollow},
+		"mute":     {"mute [シリーズ]", "シリーズの新刊通知を停止（引数なしで一覧）", true, (*Handler).mute},
+		"unmute":   {"unmute <シリーズ>", "シリーズの新刊通知を再開", true, (*Handler).unmute},
+
+		"subscribe":     {"subscribe <シリーズ>", "シリーズの新刊をDMで受け取る", false, (*Handler).subscribe},
+		"unsubscribe":   {"unsubscribe <シリーズ>", "新刊DMを停止", false, (*Handler).unsubscribe},
+		"subscriptions": {"subscriptions", "新刊DMを受け取るシリーズ一覧", false, (*Handler).subscriptions},
+	}
+}
+
+// maxListLines bounds list replies so they fit in a note
+const maxListLines = 30
+
+// lookupInterval is how often one sender may make the bot search
+// Rakuten for a series to subscribe to
+const lookupInterval = 30 * time.Second
+
+// Handler runs commands against the collection
+type Handler struct {
+	mgr      *manga.Manager
+	provider api.Provider
+	owners   map[string]bool
+	members  map[string]bool
+
+	mu      sync.Mutex
+	lookups map[string]time.Time // Last series search by sender
+}
+
+// NewHandler creates a command handler
+// owners are npubs or hex public keys allowed to manage the shared
+// collection; members manage a library of their own
+func NewHandler(mgr *manga.Manager, provider api.Provider, owners, members []string) (*Handler, error) {
+	h := &Handler{mgr: mgr, provider: provider, owners: map[string]bool{}, members: map[string]bool{}, lookups: map[string]time.Time{}}
+	for _, o := range owners {
+		pk, err := nostr.DecodePublicKey(o)
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner %s: %w", o, err)
+		}
+		h.owners[pk] = true
+	}
+	for _, m := range members {
+		pk, err := nostr.DecodePublicKey(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member %s: %w", m, err)
+		}
+		h.members[pk] = true
+	}
+	return h, nil
+}
+
+// IsOwner reports whether a public key is on the owner allowlist
+func (h *Handler) IsOwner(pubkey string) bool {
+	return h.owners[pubkey]
+}
+
+// IsMember reports whether a public key has a library of its own
+func (h *Handler) IsMember(pubkey string) bool {
+	return h.members[pubkey]
+}
+
+// library returns the library a sender manages: their own for members,
+// the shared one for owners, or nil
+func (h *Handler) library(pubkey string) *manga.Manager {
+	switch {
+	case h.IsMember(pubkey):
+		return h.mgr.ForUser(pubkey)
+	case h.IsOwner(pubkey):
+		return h.mgr
+	}
+	return nil
+}
+
+// Handle runs the command in a message from pubkey and returns the reply
+// An empty reply means the message should be ignored
+func (h *Handler) Handle(pubkey, text string) string {
+	cmd, ok := Parse(text)
+	if !ok {
+		return ""
+	}
+
+	s, ok := commands[cmd.Name]
+	if !ok {
+		if h.library(pubkey) == nil {
+			return ""
+		}
+		return fmt.Sprintf("不明なコマンド: %s\n「help」でコマンド一覧を表示します", cmd.Name)
+	}
+	if s.library && h.library(pubkey) == nil {
+		log.Printf("Ignoring %s from %s, who is neither owner nor member", cmd.Name, pubkey)
+		return ""
+	}
+
+	reply, err := s.run(h, pubkey, cmd.Arg)
+	if err != nil {
+		log.Printf("Command %s failed: %v", cmd.Name, err)
+		return "⚠️ " + err.Error()
+	}
+	return reply
+}
+
+func (h *Handler) help(from, _ string) (string, error) {
+	names := make([]string, 0, len(commands))
+	for name, s := range commands {
+		// Only show what the sender may run
+		if !s.library || h.library(from) != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("📖 コマンド一覧\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n%s\n  %s", commands[name].usage, commands[name].summary)
+	}
+	return sb.String(), nil
+}
+
+func (h *Handler) add(from, arg string) (string, error) {
+	lib := h.library(from)
+	if arg == "" {
+		return "", fmt.Errorf("使い方: %s", commands["add"].usage)
+	}
+
+	var book *api.BookInfo
+	if isbn := importer.NormalizeISBN(arg); isbn != "" {
+		found, err := h.provider.SearchByISBN(isbn)
+		if err != nil || found.Isbn != isbn {
+			return "", fmt.Errorf("ISBN %s が見つかりません", isbn)
+		}
+		book = found
+	} else {
+		books, err := h.provider.SearchByTitle(arg)
+		if err != nil {
+			return "", fmt.Errorf("検索に失敗しました: %w", err)
+		}
+		var candidates []api.BookInfo
+		for _, b := range books {
+			if b.Isbn == "" {
+				continue
+			}
+			if manga.NormalizeText(b.Title) == manga.NormalizeText(arg) {
+				candidates = []api.BookInfo{b}
+				break
+			}
+			candidates = append(candidates, b)
+		}
+
+		switch len(candidates) {
+		case 0:
+			return "", fmt.Errorf("「%s」が見つかりません", arg)
+		case 1:
+			book = &candidates[0]
+		default:
+			var sb strings.Builder
+			fmt.Fprintf(&sb, "🔍 「%s」の候補が%d件あります。ISBNで指定してください:\n", arg, len(candidates))
+			for i, b := range candidates {
+				if i == 5 {
+					sb.WriteString("\n…")
+					break
+				}
+				fmt.Fprintf(&sb, "\nadd %s\n  %s", b.Isbn, b.Title)
+			}
+			return sb.String(), nil
+		}
+	}
+
+	if existing, err := lib.GetByISBN(book.Isbn); err == nil {
+		return fmt.Sprintf("登録済みです: %s", existing.Title), nil
+	}
+	if err := lib.Add(manga.FromBookInfo(*book)); err != nil {
+		return "", fmt.Errorf("登録に失敗しました: %w", err)
+	}
+	return fmt.Sprintf("📚 登録しました: %s (%s)", book.Title, book.Isbn), nil
+}
+
+func (h *Handler) list(from, _ string) (string, error) {
+	lib := h.library(from)
+	series, err := lib.ListSeries()
+	if err != nil {
+		return "", err
+	}
+	if len(series) == 0 {
+		return "シリーズが登録されていません", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📚 シリーズ一覧 (%d)\n", len(series))
+	for i, s := range series {
+		if i == maxListLines {
+			fmt.Fprintf(&sb, "\n…他%dシリーズ", len(series)-i)
+			break
+		}
+		vols, err := lib.GetBySeries(s)
+		if err != nil {
+			return "", err
+		}
+		latest := 0
+		for _, v := range vols {
+			latest = max(latest, v.Volume)
+		}
+		fmt.Fprintf(&sb, "\n- %s: %d冊 (最新%d巻)", s, len(vols), latest)
+	}
+	return sb.String(), nil
+}
+
+func (h *Handler) gaps(from, _ string) (string, error) {
+	lib := h.library(from)
+	gaps, err := lib.Gaps()
+	if err != nil {
+		return "", err
+	}
+	if len(gaps) == 0 {
+		return "抜けている巻はありません 🎉", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🕳 抜けている巻\n")
+	for i, g := range gaps {
+		if i == maxListLines {
+			fmt.Fprintf(&sb, "\n…他%dシリーズ", len(gaps)-i)
+			break
+		}
+		vols := make([]string, len(g.Missing))
+		for j, v := range g.Missing {
+			vols[j] = fmt.Sprint(v)
+		}
+		fmt.Fprintf(&sb, "\n- %s: %s巻 (最新%d巻)", g.Series, strings.Join(vols, ", "), g.Latest)
+	}
+	return sb.String(), nil
+}
+
+func (h *Handler) follow(from, arg string) (string, error) {
+	lib := h.library(from)
+	if arg == "" {
+		follows, err := lib.ListFollows()
+		if err != nil {
+			return "", err
+		}
+		if len(follows) == 0 {
+			return "フォロー中の作者はいません", nil
+		}
+		names := make([]string, len(follows))
+		for i, f := range follows {
+			names[i] = "- " + f.Author
+		}
+		return "👤 フォロー中の作者\n\n" + strings.Join(names, "\n"), nil
+	}
+
+	if err := lib.Follow(arg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("👤 %s の新刊を通知します", arg), nil
+}
+
+func (h *Handler) unfollow(from, arg string) (string, error) {
+	lib := h.library(from)
+	if arg == "" {
+		return "", fmt.Errorf("使い方: %s", commands["unfollow"].usage)
+	}
+	if err := lib.Unfollow(arg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s のフォローを解除しました", arg), nil
+}
+
+func (h *Handler) mute(from, arg string) (string, error) {
+	lib := h.library(from)
+	if arg == "" {
+		muted, err := lib.ListMuted()
+		if err != nil {
+			return "", err
+		}
+		if len(muted) == 0 {
+			return "ミュート中のシリーズはありません", nil
+		}
+		names := make([]string, len(muted))
+		for i, m := range muted {
+			names[i] = "- " + m.Series
+		}
+		return "🔇 ミュート中のシリーズ\n\n" + strings.Join(names, "\n"), nil
+	}
+
+	if err := lib.Mute(arg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🔇 %s の新刊通知を停止しました", arg), nil
+}
+
+func (h *Handler) unmute(from, arg string) (string, error) {
+	lib := h.library(from)
+	if arg == "" {
+		return "", fmt.Errorf("使い方: %s", commands["unmute"].usage)
+	}
+	if err := lib.Unmute(arg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🔔 %s の新刊通知を再開しました", arg), nil
+}
+
+func (h *Handler) subscribe(from, arg string) (string, error) {
+	if arg == "" {
+		return "", fmt.Errorf("使い方: %s", commands["subscribe"].usage)
+	}
+
+	series, err := h.findSeries(from, arg)
+	if err != nil {
+		return "", err
+	}
+	if err := h.mgr.Subscribe(from, series); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🔔 %s の新刊をDMでお知らせします", series), nil
+}
+
+// findSeries resolves a title to the series name used in release checks
+// An exact match with a subscribed or registered series wins; otherwise
+// the series of the first numbered volume found on Rakuten is used, as
+// often as lookupInterval allows for the sender
+func (h *Handler) findSeries(from, title string) (string, error) {
+	key := manga.NormalizeText(title)
+
+	known, err := h.mgr.ListSeries()
+	if err != nil {
+		return "", err
+	}
+	subscribed, err := h.mgr.SubscribedSeries()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range append(known, subscribed...) {
+		if manga.NormalizeText(s) == key {
+			return s, nil
+		}
+	}
+
+	if len(subscribed) >= manga.MaxSubscribedSeries {
+		return "", fmt.Errorf("購読できるシリーズ数の上限（%d）に達しています", manga.MaxSubscribedSeries)
+	}
+	if wait := h.reserveLookup(from); wait > 0 {
+		return "", fmt.Errorf("検索は%d秒後にお試しください", int(wait.Round(time.Second)/time.Second))
+	}
+
+	books, err := h.provider.SearchByTitle(title)
+	if err != nil {
+		return "", fmt.Errorf("検索に失敗しました: %w", err)
+	}
+	var first string
+	for _, b := range books {
+		info := manga.ExtractVolumeInfo(b.Title)
+		if !info.HasVolume {
+			continue
+		}
+		if manga.NormalizeText(info.Title) == key {
+			return info.Title, nil
+		}
+		if first == "" {
+			first = info.Title
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("シリーズ「%s」が見つかりません", title)
+	}
+	return first, nil
+}
+
+// reserveLookup records a series search by a sender, or returns how long
+// they must wait before the next one
+func (h *Handler) reserveLookup(from string) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if wait := h.lookups[from].Add(lookupInterval).Sub(now); wait > 0 {
+		return wait
+	}
+	// Forget senders whose interval has passed
+	for pk, at := range h.lookups {
+		if now.Sub(at) >= lookupInterval {
+			delete(h.lookups, pk)
+		}
+	}
+	h.lookups[from] = now
+	return 0
+}
+
+func (h *Handler) unsubscribe(from, arg string) (string, error) {
+	if arg == "" {
+		return "", fmt.Errorf("使い方: %s", commands["unsubscribe"].usage)
+	}
+
+	series, err := h.mgr.ListSubscriptions(from)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range series {
+		if manga.NormalizeText(s) == manga.NormalizeText(arg) {
+			if err := h.mgr.Unsubscribe(from, s); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("🔕 %s の新刊DMを停止しました", s), nil
+		}
+	}
+	return "", fmt.Errorf("%s は購読していません", arg)
+}
+
+func (h *Handler) subscriptions(from, _ string) (string, error) {
+	series, err := h.mgr.ListSubscriptions(from)
+	if err != nil {
+		return "", err
+	}
+	if len(series) == 0 {
+		return "購読中のシリーズはありません\n「subscribe <シリーズ>」で新刊をDMで受け取れます", nil
+	}
+	names := make([]string, len(series))
+	for i, s := range series {
+		names[i] = "- " + s
+	}
+	return fmt.Sprintf("🔔 購読中のシリーズ (%d/%d)\n\n%s", len(series), manga.MaxSubscriptions, strings.Join(names, "\n")), nil
+}
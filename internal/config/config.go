@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,12 +16,36 @@ type Config struct {
 	Rakuten RakutenConfig `yaml:"rakuten"`
 	Database DatabaseConfig `yaml:"database"`
 	Bot BotConfig `yaml:"bot"`
+	Backup BackupConfig `yaml:"backup"`
+	Covers CoversConfig `yaml:"covers"`
+	Sync SyncConfig `yaml:"sync"`
 }
 
 // NostrConfig holds Nostr client settings
 type NostrConfig struct {
-	SecretKey string   `yaml:"secret_key"`
-	Relays    []string `yaml:"relays"`
+	SecretKey      string   `yaml:"secret_key"`      // nsec, hex or NIP-49 ncryptsec
+	PassphraseFile string   `yaml:"passphrase_file"` // Unlocks an ncryptsec secret_key
+	Relays         []string `yaml:"relays"`
+
+	Bunker              string `yaml:"bunker"`                 // NIP-46 bunker:// URL; used instead of secret_key
+	BunkerClientKeyFile string `yaml:"bunker_client_key_file"` // Key identifying this installation to the bunker
+
+	Quorum       int    `yaml:"quorum"`         // Relays that must accept each event before it leaves the outbox
+	OutboxMaxAge string `yaml:"outbox_max_age"` // Give up retrying an event after this long
+
+	MediaServer   string `yaml:"media_server"`   // Blossom server or NIP-96 upload URL; empty links Rakuten's image
+	MediaProtocol string `yaml:"media_protocol"` // blossom or nip96
+
+	Profile ProfileConfig `yaml:"profile"`
+}
+
+// ProfileConfig is the bot's Nostr profile; empty fields are not changed
+type ProfileConfig struct {
+	Name    string `yaml:"name"`
+	About   string `yaml:"about"`
+	Picture string `yaml:"picture"` // Image URL
+	NIP05   string `yaml:"nip05"`   // e.g. komikan@example.com
+	LUD16   string `yaml:"lud16"`   // Lightning address
 }
 
 // RakutenConfig holds Rakuten API settings
@@ -28,13 +55,59 @@ type RakutenConfig struct {
 
 // DatabaseConfig holds database settings
 type DatabaseConfig struct {
-	Path string `yaml:"path"`
+	Path   string `yaml:"path"`
+	Driver string `yaml:"driver"` // badger or sqlite; detected from path if empty
+
+	GCInterval     string  `yaml:"gc_interval"`      // Time between value log GC runs
+	GCDiscardRatio float64 `yaml:"gc_discard_ratio"` // Rewrite value log files at least this stale
+
+	Badger BadgerConfig `yaml:"badger"`
+}
+
+// BadgerConfig tunes Badger for low-memory devices; zero keeps the default
+type BadgerConfig struct {
+	MemTableSizeMB   int64 `yaml:"memtable_size_mb"`
+	NumMemtables     int   `yaml:"num_memtables"`
+	ValueThreshold   int64 `yaml:"value_threshold"`
+	BlockCacheSizeMB int64 `yaml:"block_cache_size_mb"`
+	ValueLogFileMB   int64 `yaml:"value_log_file_mb"`
 }
 
 // BotConfig holds bot settings
 type BotConfig struct {
 	CheckInterval         string `yaml:"check_interval"`
 	AnnounceNewReleases   bool   `yaml:"announce_new_releases"`
+
+	Owners           []string `yaml:"owners"`             // npubs that receive DM reminders and may run commands
+	Members          []string `yaml:"members"`            // npubs with their own library, managed by DM
+	Commands         bool     `yaml:"commands"`           // Accept commands by mention and DM (subscriptions from anyone)
+	LoanReminderDays int      `yaml:"loan_reminder_days"` // Days before the due date to remind
+
+	AttachCovers bool `yaml:"attach_covers"` // Attach cover images to new release announcements
+	PublishLists bool `yaml:"publish_lists"` // Publish the library, wishlist and series as NIP-51 lists
+
+	AnnouncePriceChanges bool   `yaml:"announce_price_changes"` // Alert on wishlist price drops and bonus points
+	AnnounceStockChanges bool   `yaml:"announce_stock_changes"` // Alert on restocks, preorders and limited editions selling out
+	PriceCheckInterval   string `yaml:"price_check_interval"`   // Time between price and stock checks
+}
+
+// BackupConfig holds scheduled snapshot settings
+type BackupConfig struct {
+	Dir      string `yaml:"dir"`      // Snapshot directory; empty disables snapshots
+	Interval string `yaml:"interval"` // Time between snapshots
+	Keep     int    `yaml:"keep"`     // Number of snapshots to retain
+}
+
+// SyncConfig holds relay sync settings
+type SyncConfig struct {
+	Enabled  bool   `yaml:"enabled"`  // Keep the database in sync with relays
+	Interval string `yaml:"interval"` // Time between syncs
+}
+
+// CoversConfig holds cover image cache settings
+type CoversConfig struct {
+	Dir       string `yaml:"dir"`         // Cache directory
+	MaxSizeMB int64  `yaml:"max_size_mb"` // Cache size limit; least recently used covers are removed
 }
 
 // Load loads configuration from a file
@@ -50,9 +123,45 @@ func Load(path string) (*Config, error) {
 	}
 
 	// Set defaults
+	if cfg.Nostr.Quorum <= 0 {
+		cfg.Nostr.Quorum = 2
+	}
+	if cfg.Nostr.BunkerClientKeyFile == "" {
+		cfg.Nostr.BunkerClientKeyFile = "data/bunker-client.key"
+	}
+	if cfg.Nostr.OutboxMaxAge == "" {
+		cfg.Nostr.OutboxMaxAge = "24h"
+	}
 	if cfg.Database.Path == "" {
 		cfg.Database.Path = "data/komikan.db"
 	}
+	if cfg.Database.GCInterval == "" {
+		cfg.Database.GCInterval = "6h"
+	}
+	if cfg.Database.GCDiscardRatio <= 0 || cfg.Database.GCDiscardRatio >= 1 {
+		cfg.Database.GCDiscardRatio = 0.5
+	}
+	if cfg.Bot.LoanReminderDays <= 0 {
+		cfg.Bot.LoanReminderDays = 2
+	}
+	if cfg.Bot.PriceCheckInterval == "" {
+		cfg.Bot.PriceCheckInterval = "12h"
+	}
+	if cfg.Covers.Dir == "" {
+		cfg.Covers.Dir = "data/covers"
+	}
+	if cfg.Covers.MaxSizeMB <= 0 {
+		cfg.Covers.MaxSizeMB = 200
+	}
+	if cfg.Backup.Interval == "" {
+		cfg.Backup.Interval = "24h"
+	}
+	if cfg.Backup.Keep <= 0 {
+		cfg.Backup.Keep = 7
+	}
+	if cfg.Sync.Interval == "" {
+		cfg.Sync.Interval = "15m"
+	}
 
 	return &cfg, nil
 }
@@ -63,7 +172,49 @@ func (c *Config) LoadFromEnv() {
 	if key := os.Getenv("NOSTR_SECRET_KEY"); key != "" {
 		c.Nostr.SecretKey = key
 	}
+	if bunker := os.Getenv("NOSTR_BUNKER"); bunker != "" {
+		c.Nostr.Bunker = bunker
+	}
 	if appID := os.Getenv("RAKUTEN_APP_ID"); appID != "" {
 		c.Rakuten.ApplicationID = appID
 	}
 }
+
+// passphraseCredential is the systemd credential holding the passphrase
+const passphraseCredential = "nostr-passphrase"
+
+// Passphrase returns the passphrase for an ncryptsec secret key, from the
+// NOSTR_PASSPHRASE environment variable, the systemd credential
+// nostr-passphrase (LoadCredential=) or passphrase_file, in that order.
+// The variable is removed so child processes do not inherit it.
+func (c *Config) Passphrase() (string, error) {
+	if pass, ok := os.LookupEnv("NOSTR_PASSPHRASE"); ok {
+		os.Unsetenv("NOSTR_PASSPHRASE")
+		return pass, nil
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		if pass, err := readPassphrase(filepath.Join(dir, passphraseCredential)); err == nil {
+			return pass, nil
+		}
+	}
+	if c.Nostr.PassphraseFile != "" {
+		info, err := os.Stat(c.Nostr.PassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			log.Printf("Warning: passphrase file %s is readable by other users", c.Nostr.PassphraseFile)
+		}
+		return readPassphrase(c.Nostr.PassphraseFile)
+	}
+	return "", fmt.Errorf("secret key is encrypted: set NOSTR_PASSPHRASE, the %s systemd credential or nostr.passphrase_file", passphraseCredential)
+}
+
+// readPassphrase reads a passphrase file without its trailing newline
+func readPassphrase(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
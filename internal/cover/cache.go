@@ -0,0 +1,300 @@
+package cover
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// Decoders for the formats covers are served in
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// MaxImageBytes is the largest cover that will be downloaded
+const MaxImageBytes = 10 << 20
+
+// ErrNotCached is returned when a hash is not in the cache
+var ErrNotCached = errors.New("cover not cached")
+
+// Cache stores cover images by the SHA-256 of their content
+// Originals live in <dir>/<hash[:2]>/<hash>.<ext> and thumbnails in
+// <dir>/thumbs. When the cache grows past its limit the least recently
+// used covers are removed.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	client   *http.Client
+	mu       sync.Mutex
+}
+
+// Entry describes a cached cover
+type Entry struct {
+	Hash     string // Hex SHA-256 of the image
+	Path     string
+	MimeType string
+	Size     int64
+	Width    int
+	Height   int
+}
+
+// NewCache opens a cover cache in dir, limited to maxBytes (0 for no limit)
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cover cache: %w", err)
+	}
+	return &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Download fetches an image and stores it in the cache
+func (c *Cache) Download(url string) (Entry, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to download cover: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Entry{}, fmt.Errorf("failed to download cover: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read cover: %w", err)
+	}
+	if len(data) > MaxImageBytes {
+		return Entry{}, fmt.Errorf("cover is larger than %d bytes", MaxImageBytes)
+	}
+
+	return c.Put(data)
+}
+
+// Put stores image data in the cache
+// Storing the same image again only refreshes its last use time
+func (c *Cache) Put(data []byte) (Entry, error) {
+	mime := http.DetectContentType(data)
+	ext, ok := extensions[mime]
+	if !ok {
+		return Entry{}, fmt.Errorf("unsupported cover type %s", mime)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to decode cover: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(c.dir, hash[:2], hash+ext)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		touch(path)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return Entry{}, fmt.Errorf("failed to create cover directory: %w", err)
+		}
+		// Write to a temporary file so readers never see a partial image
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return Entry{}, fmt.Errorf("failed to write cover: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return Entry{}, fmt.Errorf("failed to write cover: %w", err)
+		}
+		if _, _, err := c.prune(path); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	return Entry{
+		Hash:     hash,
+		Path:     path,
+		MimeType: mime,
+		Size:     int64(len(data)),
+		Width:    cfg.Width,
+		Height:   cfg.Height,
+	}, nil
+}
+
+// OriginalURL drops Rakuten's _ex resize parameter from a cover URL so the
+// full-size image is downloaded instead of a 200px rendition
+func OriginalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	if !q.Has("_ex") {
+		return raw
+	}
+	q.Del("_ex")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// extensions maps supported image types to file extensions
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Get returns a cached cover and marks it as recently used
+func (c *Cache) Get(hash string) (Entry, error) {
+	if len(hash) != sha256.Size*2 {
+		return Entry{}, ErrNotCached
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for mime, ext := range extensions {
+		path := filepath.Join(c.dir, hash[:2], hash+ext)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return Entry{}, fmt.Errorf("failed to open cover: %w", err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			return Entry{}, fmt.Errorf("failed to decode cover: %w", err)
+		}
+
+		touch(path)
+		return Entry{
+			Hash:     hash,
+			Path:     path,
+			MimeType: mime,
+			Size:     info.Size(),
+			Width:    cfg.Width,
+			Height:   cfg.Height,
+		}, nil
+	}
+	return Entry{}, ErrNotCached
+}
+
+// Usage returns the number of cached files and their total size
+func (c *Cache) Usage() (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.files()
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	return len(files), total, nil
+}
+
+// Prune removes least recently used files until the cache fits its limit
+func (c *Cache) Prune() (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prune("")
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// prune is Prune without locking
+// The file at keep was just written and is never removed, even if it
+// alone is over the limit.
+func (c *Cache) prune(keep string) (int, int64, error) {
+	if c.maxBytes <= 0 {
+		return 0, 0, nil
+	}
+
+	files, err := c.files()
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	removed, freed := 0, int64(0)
+	for _, f := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if f.path == keep {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, freed, fmt.Errorf("failed to remove cached cover: %w", err)
+		}
+		total -= f.size
+		freed += f.size
+		removed++
+	}
+	return removed, freed, nil
+}
+
+// files lists every file in the cache, originals and thumbnails alike
+func (c *Cache) files() ([]cachedFile, error) {
+	var files []cachedFile
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan cover cache: %w", err)
+	}
+	return files, nil
+}
+
+// touch records a use of a cached file for LRU eviction
+func touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
@@ -0,0 +1,72 @@
+package cover
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func testImage(t *testing.T, shade uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 96))
+	for i := range img.Pix {
+		img.Pix[i] = shade + uint8(i)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPutKeepsNewCoverOverBudget(t *testing.T) {
+	first, second := testImage(t, 0), testImage(t, 100)
+
+	// The budget fits one cover but not two
+	c, err := NewCache(t.TempDir(), int64(max(len(first), len(second)))+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := c.Put(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Put(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(b.Path); err != nil {
+		t.Fatalf("new cover was evicted: %v", err)
+	}
+	if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+		t.Errorf("old cover was kept over budget: %v", err)
+	}
+
+	thumb, err := c.Thumbnail(b.Hash, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(thumb); err != nil {
+		t.Fatalf("new thumbnail was evicted: %v", err)
+	}
+}
+
+func TestPutKeepsCoverLargerThanBudget(t *testing.T) {
+	c, err := NewCache(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := c.Put(testImage(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(e.Path); err != nil {
+		t.Fatalf("cover was evicted: %v", err)
+	}
+	if _, err := c.Get(e.Hash); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package cover
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+// DefaultThumbnailSize is the longest side of a thumbnail in pixels
+const DefaultThumbnailSize = 240
+
+// Thumbnail returns the path of a JPEG thumbnail of a cached cover
+// whose longest side is at most size pixels, generating it if needed
+func (c *Cache) Thumbnail(hash string, size int) (string, error) {
+	if size <= 0 {
+		size = DefaultThumbnailSize
+	}
+
+	orig, err := c.Get(hash)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := filepath.Join(c.dir, "thumbs", fmt.Sprintf("%s-%d.jpg", hash, size))
+	if _, err := os.Stat(path); err == nil {
+		touch(path)
+		return path, nil
+	}
+
+	f, err := os.Open(orig.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open cover: %w", err)
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode cover: %w", err)
+	}
+
+	thumb := scale(src, size)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	if err := jpeg.Encode(out, thumb, &jpeg.Options{Quality: 85}); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+
+	if _, _, err := c.prune(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// scale fits an image within size x size, keeping its aspect ratio
+// Images already small enough are only converted to RGBA
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
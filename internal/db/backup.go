@@ -0,0 +1,279 @@
+package db
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Backup streams a backup of the database to w while it stays online
+// Pass since=0 for a full backup, or the version returned by a previous
+// Backup call for an incremental one. Returns the version to use as
+// since for the next incremental backup.
+func (d *DB) Backup(w io.Writer, since uint64) (uint64, error) {
+	if d.badger == nil {
+		return 0, fmt.Errorf("streaming backup: %w", ErrNotSupported)
+	}
+
+	bw := bufio.NewWriter(w)
+	next, err := d.badger.Backup(bw, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to back up database: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush backup: %w", err)
+	}
+	return next, nil
+}
+
+// Load restores a backup written by Backup
+// Incremental backups must be loaded in order after their full backup
+func (d *DB) Load(r io.Reader) error {
+	if d.badger == nil {
+		return fmt.Errorf("loading backups: %w", ErrNotSupported)
+	}
+	if err := d.badger.Load(bufio.NewReader(r), 256); err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	return nil
+}
+
+// VerifyBackup checks that a backup can be loaded and returns its key count
+// The backup is loaded into a throwaway in-memory database
+func VerifyBackup(r io.Reader) (int, error) {
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts.Logger = nil
+
+	mem, err := badger.Open(opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	defer mem.Close()
+
+	tmp := &DB{store: &badgerStore{db: mem}, driver: DriverBadger, badger: mem}
+	if err := tmp.Load(r); err != nil {
+		return 0, err
+	}
+
+	keys, err := tmp.ListPrefix("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan backup: %w", err)
+	}
+	return len(keys), nil
+}
+
+// sqliteHeader starts every SQLite database file, including the
+// snapshots written with VACUUM INTO
+const sqliteHeader = "SQLite format 3\x00"
+
+// BackupDriver reports which driver a backup file was written by
+// SQLite snapshots are database files; anything else is taken to be a
+// Badger backup stream.
+func BackupDriver(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if string(header[:n]) == sqliteHeader {
+		return DriverSQLite, nil
+	}
+	return DriverBadger, nil
+}
+
+// LoadFile restores a backup or snapshot file into the database
+// The file must have been written by the database's driver; use
+// CopyAll to move data between drivers.
+func (d *DB) LoadFile(path string) error {
+	driver, err := BackupDriver(path)
+	if err != nil {
+		return err
+	}
+	if driver != d.driver {
+		return fmt.Errorf("%s is a %s backup and cannot be loaded into a %s database", path, driver, d.driver)
+	}
+
+	if driver == DriverSQLite {
+		store, err := openSQLiteSnapshot(path)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		return d.loadEntries(&DB{store: store, driver: DriverSQLite, path: path})
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.Load(f)
+}
+
+// loadEntries writes every key of src into the database in batches,
+// overwriting keys that exist in both
+func (d *DB) loadEntries(src *DB) error {
+	entries, err := src.ListPrefixEntries("")
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	for len(entries) > 0 {
+		err := d.Update(func(txn *Txn) error {
+			for len(entries) > 0 && !txn.Full() {
+				if err := txn.Set([]byte(entries[0].Key), entries[0].Value); err != nil {
+					return err
+				}
+				entries = entries[1:]
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyBackupFile checks that a backup or snapshot file of either driver
+// can be loaded, and returns the driver and key count
+func VerifyBackupFile(path string) (string, int, error) {
+	driver, err := BackupDriver(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if driver == DriverSQLite {
+		store, err := openSQLiteSnapshot(path)
+		if err != nil {
+			return driver, 0, err
+		}
+		defer store.Close()
+		keys, err := (&DB{store: store, driver: DriverSQLite, path: path}).ListPrefix("")
+		if err != nil {
+			return driver, 0, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		return driver, len(keys), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return driver, 0, err
+	}
+	defer f.Close()
+	keys, err := VerifyBackup(f)
+	return driver, keys, err
+}
+
+// snapshotPrefix and snapshotSuffix name files written by WriteSnapshot
+const (
+	snapshotPrefix = "komikan-"
+	snapshotSuffix = ".bak"
+)
+
+// WriteSnapshot writes a full backup into dir as a timestamped file
+// The file is written under a temporary name and renamed when complete,
+// so an interrupted snapshot never replaces a good one. Badger snapshots
+// are streaming backups; SQLite snapshots are copies of the database file.
+func (d *DB) WriteSnapshot(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	name := snapshotPrefix + time.Now().Format("20060102-150405") + snapshotSuffix
+	path := filepath.Join(dir, name)
+
+	if sqlite, ok := d.store.(*sqliteStore); ok {
+		tmpPath := path + ".tmp"
+		defer os.Remove(tmpPath) // No-op after a successful rename
+		if err := sqlite.snapshot(tmpPath); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			return "", fmt.Errorf("failed to finalize snapshot: %w", err)
+		}
+		return path, nil
+	}
+
+	f, err := os.CreateTemp(dir, name+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath) // No-op after a successful rename
+
+	if _, err := d.Backup(f, 0); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+
+	return path, nil
+}
+
+// ListSnapshots returns snapshot files in dir, oldest first
+func ListSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snapshots []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		snapshots = append(snapshots, filepath.Join(dir, name))
+	}
+	// Timestamped names sort chronologically
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// PruneSnapshots removes all but the newest keep snapshots in dir
+// Returns the removed paths
+func PruneSnapshots(dir string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("retention must keep at least one snapshot")
+	}
+
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) <= keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, path := range snapshots[:len(snapshots)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
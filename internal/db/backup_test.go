@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotsRestoreIntoTheirDriver(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			src := openTestDB(t, driver, BadgerOptions{})
+			for i := range 50 {
+				if err := src.Set([]byte(fmt.Sprintf("k%02d", i)), []byte("v")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path, err := src.WriteSnapshot(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := BackupDriver(path); err != nil || got != driver {
+				t.Fatalf("BackupDriver = %q, %v; want %q", got, err, driver)
+			}
+			if got, keys, err := VerifyBackupFile(path); err != nil || got != driver || keys != 50 {
+				t.Fatalf("VerifyBackupFile = %q, %d, %v; want %q, 50", got, keys, err, driver)
+			}
+
+			dst := openTestDB(t, driver, BadgerOptions{})
+			dst.batchLimit = 7
+			if err := dst.LoadFile(path); err != nil {
+				t.Fatalf("LoadFile: %v", err)
+			}
+			keys, err := dst.ListPrefix("k")
+			if err != nil || len(keys) != 50 {
+				t.Fatalf("restored %d keys, %v; want 50", len(keys), err)
+			}
+		})
+	}
+}
+
+func TestLoadFileRejectsOtherDriver(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			src := openTestDB(t, driver, BadgerOptions{})
+			if err := src.Set([]byte("k"), []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+			path, err := src.WriteSnapshot(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			other := DriverSQLite
+			if driver == DriverSQLite {
+				other = DriverBadger
+			}
+			dst := openTestDB(t, other, BadgerOptions{})
+			err = dst.LoadFile(path)
+			if err == nil || !strings.Contains(err.Error(), "cannot be loaded into a "+other+" database") {
+				t.Fatalf("LoadFile = %v, want a driver mismatch error", err)
+			}
+			if empty, _ := dst.IsEmpty(); !empty {
+				t.Error("target database was written to")
+			}
+		})
+	}
+}
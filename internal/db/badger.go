@@ -1,140 +1,141 @@
 package db
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
-// DB represents a BadgerDB database
-type DB struct {
+// badgerStore implements Store on BadgerDB
+type badgerStore struct {
 	db *badger.DB
 }
 
-// Config holds database configuration
-type Config struct {
-	Path string // Database directory path
+// BadgerOptions tunes Badger for low-memory devices
+// Zero values keep Badger's defaults
+type BadgerOptions struct {
+	MemTableSizeMB   int64 // Size of each memtable (default 64)
+	NumMemtables     int   // Memtables held in memory (default 5)
+	ValueThreshold   int64 // Values larger than this (bytes) go to the value log (default 1MB)
+	BlockCacheSizeMB int64 // Block cache size (default 256)
+	ValueLogFileMB   int64 // Maximum value log file size (default 1GB)
 }
 
-// NewDB creates a new database connection
-func NewDB(cfg Config) (*DB, error) {
-	opts := badger.DefaultOptions(cfg.Path)
+// openBadger opens a BadgerDB directory
+func openBadger(path string, tune BadgerOptions) (*badger.DB, error) {
+	opts := badger.DefaultOptions(path)
 
 	// Disable default logger for cleaner output
 	opts.Logger = nil
 
 	// Tune for Raspberry Pi 3 (1GB RAM)
-	// Use default options for v4 which are already optimized
+	// Use default options for v4 unless overridden in config
 	opts.InMemory = false
+	if tune.MemTableSizeMB > 0 {
+		opts = opts.WithMemTableSize(tune.MemTableSizeMB << 20)
+	}
+	if tune.NumMemtables > 0 {
+		opts = opts.WithNumMemtables(tune.NumMemtables)
+	}
+	if tune.ValueThreshold > 0 {
+		opts = opts.WithValueThreshold(tune.ValueThreshold)
+	}
+	if tune.BlockCacheSizeMB > 0 {
+		opts = opts.WithBlockCacheSize(tune.BlockCacheSizeMB << 20)
+	}
+	if tune.ValueLogFileMB > 0 {
+		opts = opts.WithValueLogFileSize(tune.ValueLogFileMB << 20)
+	}
 
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-
-	return &DB{db: db}, nil
+	return db, nil
 }
 
-// Close closes the database connection
-func (d *DB) Close() error {
-	return d.db.Close()
+// openMemory opens an empty in-memory Badger database without migrating
+// it, as scratch space
+func openMemory() (*DB, error) {
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts.Logger = nil
+	bdb, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	return &DB{store: &badgerStore{db: bdb}, driver: DriverBadger, badger: bdb, watch: &watchers{}, batchLimit: badgerBatchLimit(bdb)}, nil
 }
 
-// Set stores a value by key
-func (d *DB) Set(key, value []byte) error {
-	return d.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, value)
+func (s *badgerStore) Update(fn func(txn StoreTxn) error) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return fn(badgerTxn{txn: txn})
 	})
 }
 
-// Get retrieves a value by key
-func (d *DB) Get(key []byte) ([]byte, error) {
-	var val []byte
-	err := d.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
-		if err != nil {
-			return err
-		}
-		val, err = item.ValueCopy(nil)
-		return err
+func (s *badgerStore) View(fn func(txn StoreTxn) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(badgerTxn{txn: txn})
 	})
-	if err != nil {
-		return nil, err
-	}
-	return val, nil
 }
 
-// Delete removes a key
-func (d *DB) Delete(key []byte) error {
-	return d.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(key)
-	})
+func (s *badgerStore) Close() error {
+	return s.db.Close()
 }
 
-// SetJSON stores a JSON-encoded value
-func (d *DB) SetJSON(key string, value interface{}) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-	return d.Set([]byte(key), data)
+// badgerTxn implements StoreTxn on a Badger transaction
+type badgerTxn struct {
+	txn *badger.Txn
 }
 
-// GetJSON retrieves and decodes a JSON value
-func (d *DB) GetJSON(key string, dest interface{}) error {
-	data, err := d.Get([]byte(key))
-	if err != nil {
-		return err
+func (t badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
 	}
-	if err := json.Unmarshal(data, dest); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return item.ValueCopy(nil)
 }
 
-// ListPrefix returns all keys with a given prefix
-func (d *DB) ListPrefix(prefix string) ([][]byte, error) {
-	var keys [][]byte
-	err := d.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-
-		prefixBytes := []byte(prefix)
-		for it.Seek(prefixBytes); it.ValidForPrefix(prefixBytes); it.Next() {
-			item := it.Item()
-			key := make([]byte, len(item.Key()))
-			copy(key, item.Key())
-			keys = append(keys, key)
-		}
-		return nil
-	})
-	return keys, err
+func (t badgerTxn) Set(key, value []byte) error {
+	return t.txn.Set(key, value)
+}
+
+func (t badgerTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
 }
 
-// ListPrefixJSON returns all JSON values with a given prefix
-func (d *DB) ListPrefixJSON(prefix string) ([]json.RawMessage, error) {
-	var values []json.RawMessage
-	err := d.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-
-		prefixBytes := []byte(prefix)
-		for it.Seek(prefixBytes); it.ValidForPrefix(prefixBytes); it.Next() {
-			item := it.Item()
-			val, err := item.ValueCopy(nil)
+func (t badgerTxn) Iterate(prefix, start, end []byte, keysOnly bool, fn func(key, value []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = !keysOnly
+	opts.Prefix = prefix
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		key := item.KeyCopy(nil)
+		if len(end) > 0 && string(key) >= string(end) {
+			break
+		}
+
+		var val []byte
+		if !keysOnly {
+			var err error
+			val, err = item.ValueCopy(nil)
 			if err != nil {
 				return err
 			}
-			values = append(values, json.RawMessage(val))
 		}
-		return nil
-	})
-	return values, err
-}
 
-// RunGC manually triggers garbage collection
-// Call this periodically to reclaim disk space
-func (d *DB) RunGC() error {
-	return d.db.RunValueLogGC(0.5)
+		if err := fn(key, val); err != nil {
+			if errors.Is(err, errStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
 }
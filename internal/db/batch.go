@@ -0,0 +1,88 @@
+package db
+
+import "github.com/dgraph-io/badger/v4"
+
+// Batch limits for operations spanning many records. Badger rejects
+// transactions above a size derived from the memtable size, so large
+// rewrites are committed in several transactions of bounded size.
+const (
+	batchWrites = 2000 // Writes after which a batch is committed
+	batchReads  = 500  // Entries read at a time
+)
+
+// badgerBatchLimit lowers batchWrites to fit Badger's transaction limits,
+// allowing for values of about 1 KB
+func badgerBatchLimit(bdb *badger.DB) int {
+	limit := min(int64(batchWrites), bdb.MaxBatchCount()/4, bdb.MaxBatchSize()/1024)
+	return int(max(limit, 1))
+}
+
+// Full reports whether the transaction has written enough that a batched
+// operation should commit before going on
+func (t *Txn) Full() bool {
+	limit := t.limit
+	if limit == 0 {
+		limit = batchWrites
+	}
+	return t.changes != nil && len(*t.changes) >= limit
+}
+
+// UpdateEach calls fn for every entry with prefix, in key order, in
+// read-write transactions that are committed every few thousand writes
+// A failure leaves earlier batches committed, so fn must be safe to run
+// again on entries it has already handled. fn may rewrite or delete the
+// entry it is given.
+func (d *DB) UpdateEach(prefix string, fn func(txn *Txn, e Entry) error) error {
+	after := ""
+	for {
+		var last string
+		more := false
+		err := d.Update(func(txn *Txn) error {
+			entries, err := txn.listFrom(prefix, after, batchReads)
+			if err != nil {
+				return err
+			}
+			last, more = "", len(entries) == batchReads
+			for i, e := range entries {
+				if err := fn(txn, e); err != nil {
+					return err
+				}
+				last = e.Key
+				if txn.Full() {
+					more = more || i < len(entries)-1
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !more || last == "" {
+			return nil
+		}
+		after = last
+	}
+}
+
+// listFrom returns up to limit entries with prefix whose keys sort after
+// the given key, or from the start of the prefix if after is empty
+func (t *Txn) listFrom(prefix, after string, limit int) ([]Entry, error) {
+	start := prefix
+	if after != "" {
+		start = after
+	}
+	var entries []Entry
+	err := t.txn.Iterate([]byte(t.ns+prefix), []byte(t.ns+start), nil, false, func(key, value []byte) error {
+		k := string(key[len(t.ns):])
+		if k == after {
+			return nil
+		}
+		entries = append(entries, Entry{Key: k, Value: value})
+		if len(entries) == limit {
+			return errStopIteration
+		}
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,393 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// IndexPrefix is the keyspace for secondary index entries
+// Index entries are derived data: exports skip them and restores rebuild them
+const IndexPrefix = "idx:"
+
+// indexSeparator separates the indexed value from the record ID in index keys
+const indexSeparator = "\x00"
+
+// KeyCodec converts between record IDs and storage keys
+type KeyCodec interface {
+	Encode(id string) string
+	Decode(key string) (id string, ok bool)
+}
+
+// PrefixCodec stores records under a fixed key prefix, e.g. "manga:isbn:"
+type PrefixCodec string
+
+// Encode returns the storage key for id
+func (p PrefixCodec) Encode(id string) string {
+	return string(p) + id
+}
+
+// Decode returns the record ID for a storage key
+func (p PrefixCodec) Decode(key string) (string, bool) {
+	if !strings.HasPrefix(key, string(p)) {
+		return "", false
+	}
+	return key[len(p):], true
+}
+
+// Prefix returns the key prefix shared by all records
+func (p PrefixCodec) Prefix() string {
+	return string(p)
+}
+
+// Collection stores JSON records of type T under a key codec and keeps
+// its secondary indexes in sync within the same transaction
+type Collection[T any] struct {
+	name    string
+	codec   PrefixCodec
+	id      func(T) string
+	indexes []*Index[T]
+}
+
+// Index maps derived values of a record to record IDs
+type Index[T any] struct {
+	coll   *Collection[T]
+	name   string
+	values func(T) []string
+}
+
+// reindexer is implemented by every Collection, regardless of T
+type reindexer interface {
+	Reindex(d *DB) error
+}
+
+// collections holds every collection created, for RebuildIndexes
+var collections []reindexer
+
+// NewCollection creates a collection storing records under prefix
+// id returns the record's unique ID within the collection
+func NewCollection[T any](prefix string, id func(T) string) *Collection[T] {
+	c := &Collection[T]{
+		name:  strings.TrimSuffix(prefix, ":"),
+		codec: PrefixCodec(prefix),
+		id:    id,
+	}
+	collections = append(collections, c)
+	return c
+}
+
+// AddIndex declares a secondary index
+// values returns the index values for a record; empty values are skipped
+func (c *Collection[T]) AddIndex(name string, values func(T) []string) *Index[T] {
+	idx := &Index[T]{coll: c, name: name, values: values}
+	c.indexes = append(c.indexes, idx)
+	return idx
+}
+
+// Key returns the storage key for a record ID
+func (c *Collection[T]) Key(id string) string {
+	return c.codec.Encode(id)
+}
+
+// Get retrieves a record by ID
+func (c *Collection[T]) Get(txn *Txn, id string) (T, error) {
+	var v T
+	err := txn.GetJSON(c.Key(id), &v)
+	return v, err
+}
+
+// Put stores a record and updates its index entries
+func (c *Collection[T]) Put(txn *Txn, v T) error {
+	id := c.id(v)
+	if id == "" {
+		return fmt.Errorf("%s: record has no ID", c.name)
+	}
+
+	if len(c.indexes) > 0 {
+		old, err := c.Get(txn, id)
+		switch {
+		case err == nil:
+			if err := c.unindex(txn, id, old); err != nil {
+				return err
+			}
+		case !IsNotFound(err):
+			return err
+		}
+	}
+
+	if err := txn.SetJSON(c.Key(id), v); err != nil {
+		return err
+	}
+	return c.index(txn, id, v)
+}
+
+// Delete removes a record and its index entries
+func (c *Collection[T]) Delete(txn *Txn, id string) error {
+	if len(c.indexes) > 0 {
+		old, err := c.Get(txn, id)
+		switch {
+		case err == nil:
+			if err := c.unindex(txn, id, old); err != nil {
+				return err
+			}
+		case !IsNotFound(err):
+			return err
+		}
+	}
+	return txn.Delete([]byte(c.Key(id)))
+}
+
+// PutBatch stores many records, splitting them into several transactions
+// when a single one would grow too large. Each record is written
+// atomically with its index entries.
+func (c *Collection[T]) PutBatch(d *DB, items []T) error {
+	for start := 0; start < len(items); {
+		next := start
+		err := d.Update(func(txn *Txn) error {
+			next = start
+			for next < len(items) && !txn.Full() {
+				if err := c.Put(txn, items[next]); err != nil {
+					return err
+				}
+				next++
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: batch write failed at record %d: %w", c.name, next, err)
+		}
+		start = next
+	}
+	return nil
+}
+
+// Query filters, orders and paginates records
+type Query[T any] struct {
+	Filter func(T) bool      // Keep records for which Filter returns true
+	Less   func(a, b T) bool // Sort order; key order when nil
+	Offset int
+	Limit  int // 0 means no limit
+}
+
+// Page is one page of query results
+type Page[T any] struct {
+	Items []T
+	Total int // Matching records before pagination
+}
+
+// Scan iterates over all records and applies the query
+// Records that fail to decode are returned as an error rather than skipped
+func (c *Collection[T]) Scan(txn *Txn, q Query[T]) (Page[T], error) {
+	entries, err := txn.ListPrefixEntries(c.codec.Prefix())
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	items := make([]T, 0, len(entries))
+	for _, e := range entries {
+		var v T
+		if err := json.Unmarshal(e.Value, &v); err != nil {
+			return Page[T]{}, fmt.Errorf("%s: failed to decode %s: %w", c.name, e.Key, err)
+		}
+		items = append(items, v)
+	}
+
+	return applyQuery(items, q), nil
+}
+
+// GetMany retrieves records by ID, skipping IDs that no longer exist
+func (c *Collection[T]) GetMany(txn *Txn, ids []string) ([]T, error) {
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		v, err := c.Get(txn, id)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func applyQuery[T any](items []T, q Query[T]) Page[T] {
+	if q.Filter != nil {
+		filtered := items[:0]
+		for _, v := range items {
+			if q.Filter(v) {
+				filtered = append(filtered, v)
+			}
+		}
+		items = filtered
+	}
+
+	if q.Less != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			return q.Less(items[i], items[j])
+		})
+	}
+
+	page := Page[T]{Total: len(items)}
+	if q.Offset >= len(items) {
+		page.Items = []T{}
+		return page
+	}
+	items = items[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(items) {
+		items = items[:q.Limit]
+	}
+	page.Items = items
+	return page
+}
+
+// Reindex drops and rebuilds all index entries of the collection
+// The work is committed in batches, so indexes are incomplete until it
+// returns; running it again after a failure starts over. Records that
+// cannot be decoded are logged and left out of the indexes.
+func (c *Collection[T]) Reindex(d *DB) error {
+	if len(c.indexes) == 0 {
+		return nil
+	}
+
+	for _, idx := range c.indexes {
+		err := d.UpdateEach(idx.prefix(), func(txn *Txn, e Entry) error {
+			return txn.Delete([]byte(e.Key))
+		})
+		if err != nil {
+			return fmt.Errorf("%s: failed to drop index %s: %w", c.name, idx.name, err)
+		}
+	}
+
+	return d.UpdateEach(c.codec.Prefix(), func(txn *Txn, e Entry) error {
+		var v T
+		if err := json.Unmarshal(e.Value, &v); err != nil {
+			// Left unindexed rather than blocking every other record
+			log.Printf("%s: not indexing %s: %v", c.name, e.Key, err)
+			return nil
+		}
+		id, _ := c.codec.Decode(e.Key)
+		return c.index(txn, id, v)
+	})
+}
+
+// RebuildIndexes rebuilds the indexes of every collection, including
+// those inside namespaces, one batch at a time
+// Used after restoring data, since exports do not include index entries
+func (d *DB) RebuildIndexes() error {
+	for _, c := range collections {
+		if err := c.Reindex(d); err != nil {
+			return err
+		}
+	}
+
+	names, err := d.Namespaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := d.Namespace(name).RebuildIndexes(); err != nil {
+			return fmt.Errorf("namespace %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Collection[T]) index(txn *Txn, id string, v T) error {
+	for _, idx := range c.indexes {
+		for _, val := range idx.values(v) {
+			if val == "" {
+				continue
+			}
+			if err := txn.Set([]byte(idx.key(val, id)), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Collection[T]) unindex(txn *Txn, id string, v T) error {
+	for _, idx := range c.indexes {
+		for _, val := range idx.values(v) {
+			if val == "" {
+				continue
+			}
+			if err := txn.Delete([]byte(idx.key(val, id))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (idx *Index[T]) prefix() string {
+	return IndexPrefix + idx.coll.name + ":" + idx.name + ":"
+}
+
+func (idx *Index[T]) key(value, id string) string {
+	return idx.prefix() + value + indexSeparator + id
+}
+
+// Lookup returns the IDs of records with exactly the given index value
+func (idx *Index[T]) Lookup(txn *Txn, value string) ([]string, error) {
+	keys, err := txn.ListPrefixKeys(idx.prefix() + value + indexSeparator)
+	if err != nil {
+		return nil, err
+	}
+	return idx.ids(keys), nil
+}
+
+// Range returns the IDs of records with index values in [from, to)
+// in index order. An empty to means no upper bound.
+func (idx *Index[T]) Range(txn *Txn, from, to string) ([]string, error) {
+	start := idx.prefix() + from
+	end := ""
+	if to != "" {
+		end = idx.prefix() + to
+	}
+	keys, err := txn.ListRangeKeys(idx.prefix(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	return idx.ids(keys), nil
+}
+
+// Values returns the distinct index values in order
+func (idx *Index[T]) Values(txn *Txn) ([]string, error) {
+	keys, err := txn.ListPrefixKeys(idx.prefix())
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, idx.prefix())
+		val, _, _ := strings.Cut(rest, indexSeparator)
+		if len(values) == 0 || values[len(values)-1] != val {
+			values = append(values, val)
+		}
+	}
+	return values, nil
+}
+
+func (idx *Index[T]) ids(keys []string) []string {
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if i := strings.LastIndex(k, indexSeparator); i >= 0 {
+			ids = append(ids, k[i+len(indexSeparator):])
+		}
+	}
+	return ids
+}
+
+// Find returns the records with exactly the given index value
+func (idx *Index[T]) Find(txn *Txn, value string) ([]T, error) {
+	ids, err := idx.Lookup(txn, value)
+	if err != nil {
+		return nil, err
+	}
+	return idx.coll.GetMany(txn, ids)
+}
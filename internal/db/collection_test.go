@@ -0,0 +1,280 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type testBook struct {
+	ID     string   `json:"id"`
+	Series string   `json:"series"`
+	Tags   []string `json:"tags"`
+}
+
+var (
+	testBooks = NewCollection[testBook]("test:book:", func(b testBook) string { return b.ID })
+
+	testBySeries = testBooks.AddIndex("series", func(b testBook) []string { return []string{b.Series} })
+	testByTag    = testBooks.AddIndex("tag", func(b testBook) []string { return b.Tags })
+)
+
+func putBooks(t *testing.T, d *DB, books ...testBook) {
+	t.Helper()
+	err := d.Update(func(txn *Txn) error {
+		for _, b := range books {
+			if err := testBooks.Put(txn, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lookup(t *testing.T, d *DB, idx *Index[testBook], value string) []string {
+	t.Helper()
+	var ids []string
+	err := d.View(func(txn *Txn) error {
+		var err error
+		ids, err = idx.Lookup(txn, value)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// indexKeys returns every index entry of the test collection
+func indexKeys(t *testing.T, d *DB) []string {
+	t.Helper()
+	keys, err := d.ListPrefix(IndexPrefix + "test:book:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = string(k)
+	}
+	return out
+}
+
+func TestCollectionKeepsIndexesInSync(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			putBooks(t, d,
+				testBook{ID: "1", Series: "A", Tags: []string{"x", "y"}},
+				testBook{ID: "2", Series: "A", Tags: []string{"y"}},
+				testBook{ID: "3", Series: "B"},
+			)
+
+			if got := lookup(t, d, testBySeries, "A"); !slices.Equal(got, []string{"1", "2"}) {
+				t.Errorf("series A = %v", got)
+			}
+			if got := lookup(t, d, testByTag, "y"); !slices.Equal(got, []string{"1", "2"}) {
+				t.Errorf("tag y = %v", got)
+			}
+
+			// Changing a record moves its entries
+			putBooks(t, d, testBook{ID: "1", Series: "B", Tags: []string{"z"}})
+			if got := lookup(t, d, testBySeries, "A"); !slices.Equal(got, []string{"2"}) {
+				t.Errorf("series A after update = %v", got)
+			}
+			if got := lookup(t, d, testByTag, "x"); len(got) != 0 {
+				t.Errorf("tag x after update = %v", got)
+			}
+
+			// Deleting a record removes them
+			err := d.Update(func(txn *Txn) error { return testBooks.Delete(txn, "2") })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lookup(t, d, testByTag, "y"); len(got) != 0 {
+				t.Errorf("tag y after delete = %v", got)
+			}
+
+			err = d.View(func(txn *Txn) error {
+				values, err := testBySeries.Values(txn)
+				if err != nil {
+					return err
+				}
+				if !slices.Equal(values, []string{"B"}) {
+					t.Errorf("Values = %v", values)
+				}
+				ids, err := testByTag.Range(txn, "a", "z")
+				if err != nil {
+					return err
+				}
+				if len(ids) != 0 {
+					t.Errorf("Range [a, z) = %v, want nothing", ids)
+				}
+				found, err := testBySeries.Find(txn, "B")
+				if err != nil {
+					return err
+				}
+				if len(found) != 2 || found[0].ID != "1" || found[1].ID != "3" {
+					t.Errorf("Find(B) = %v", found)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollectionScanQuery(t *testing.T) {
+	d := openTestDB(t, DriverBadger, BadgerOptions{})
+	for i := range 10 {
+		putBooks(t, d, testBook{ID: fmt.Sprintf("%02d", i), Series: fmt.Sprint(i % 2)})
+	}
+
+	err := d.View(func(txn *Txn) error {
+		page, err := testBooks.Scan(txn, Query[testBook]{
+			Filter: func(b testBook) bool { return b.Series == "0" },
+			Less:   func(a, b testBook) bool { return a.ID > b.ID },
+			Offset: 1,
+			Limit:  2,
+		})
+		if err != nil {
+			return err
+		}
+		if page.Total != 5 {
+			t.Errorf("Total = %d, want 5", page.Total)
+		}
+		if len(page.Items) != 2 || page.Items[0].ID != "06" || page.Items[1].ID != "04" {
+			t.Errorf("Items = %v, want 06 and 04", page.Items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRebuildIndexes(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			ns := d.Namespace("member")
+			putBooks(t, d, testBook{ID: "1", Series: "A", Tags: []string{"x"}})
+			putBooks(t, ns, testBook{ID: "9", Series: "N"})
+			want, nsWant := indexKeys(t, d), indexKeys(t, ns)
+
+			// A stale entry, a missing entry and a corrupt record
+			err := d.Update(func(txn *Txn) error {
+				if err := txn.Set([]byte(testBySeries.key("gone", "7")), nil); err != nil {
+					return err
+				}
+				if err := txn.Delete([]byte(testByTag.key("x", "1"))); err != nil {
+					return err
+				}
+				return txn.Set([]byte(testBooks.Key("bad")), []byte("{"))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ns.Update(func(txn *Txn) error { return txn.Delete([]byte(testBySeries.key("N", "9"))) }); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.RebuildIndexes(); err != nil {
+				t.Fatalf("RebuildIndexes: %v", err)
+			}
+			if got := indexKeys(t, d); !slices.Equal(got, want) {
+				t.Errorf("index after rebuild = %q, want %q", got, want)
+			}
+			if got := indexKeys(t, ns); !slices.Equal(got, nsWant) {
+				t.Errorf("namespace index after rebuild = %q, want %q", got, nsWant)
+			}
+		})
+	}
+}
+
+// manyBooks returns books with enough index entries that a few thousand
+// of them exceed a Badger transaction with a small memtable
+func manyBooks(n int) []testBook {
+	books := make([]testBook, n)
+	for i := range books {
+		tags := make([]string, 8)
+		for j := range tags {
+			tags[j] = fmt.Sprintf("tag-%d-%d", j, i%50)
+		}
+		books[i] = testBook{ID: fmt.Sprintf("%05d", i), Series: fmt.Sprint(i % 100), Tags: tags}
+	}
+	return books
+}
+
+func TestLargeCollectionInBatches(t *testing.T) {
+	d := openTestDB(t, DriverBadger, BadgerOptions{MemTableSizeMB: 8})
+	books := manyBooks(3000)
+
+	// The same writes in one transaction are too big, so the test
+	// exercises the batching
+	err := d.Update(func(txn *Txn) error {
+		for _, b := range books {
+			if err := testBooks.Put(txn, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, badger.ErrTxnTooBig) {
+		t.Fatalf("single transaction = %v, want ErrTxnTooBig", err)
+	}
+
+	if err := testBooks.PutBatch(d, books); err != nil {
+		t.Fatalf("PutBatch: %v", err)
+	}
+	want := indexKeys(t, d)
+	if len(want) != 3000*9 {
+		t.Fatalf("%d index entries, want %d", len(want), 3000*9)
+	}
+
+	if err := d.RebuildIndexes(); err != nil {
+		t.Fatalf("RebuildIndexes: %v", err)
+	}
+	if got := indexKeys(t, d); !slices.Equal(got, want) {
+		t.Errorf("rebuild changed the index: %d entries, want %d", len(got), len(want))
+	}
+}
+
+func TestUpdateEachDeletingEntries(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			d.batchLimit = 7 // Several batches for a small data set
+			for i := range 100 {
+				if err := d.Set(fmt.Appendf(nil, "del:%03d", i), []byte("v")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.Set([]byte("keep"), nil); err != nil {
+				t.Fatal(err)
+			}
+
+			seen := 0
+			err := d.UpdateEach("del:", func(txn *Txn, e Entry) error {
+				seen++
+				return txn.Delete([]byte(e.Key))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen != 100 {
+				t.Errorf("fn called %d times, want 100", seen)
+			}
+			if keys, _ := d.ListPrefix(""); len(keys) != 1 || string(keys[0]) != "keep" {
+				t.Errorf("keys left = %q, want only keep", keys)
+			}
+		})
+	}
+}
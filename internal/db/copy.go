@@ -0,0 +1,53 @@
+package db
+
+import "fmt"
+
+// CopyAll copies every key from src into dst, including meta and index
+// records, so dst becomes an exact copy regardless of storage driver
+// dst must be empty. Returns the number of keys copied.
+func CopyAll(dst, src *DB) (int, error) {
+	empty, err := dst.IsEmpty()
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect target database: %w", err)
+	}
+	if !empty {
+		return 0, fmt.Errorf("target database is not empty")
+	}
+
+	entries, err := src.ListPrefixEntries("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read source database: %w", err)
+	}
+
+	const batchSize = 1000
+	for start := 0; start < len(entries); start += batchSize {
+		end := min(start+batchSize, len(entries))
+		err := dst.Update(func(txn *Txn) error {
+			for _, e := range entries[start:end] {
+				if err := txn.Set([]byte(e.Key), e.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return start, fmt.Errorf("failed to write target database: %w", err)
+		}
+	}
+
+	// Verify the copy key by key
+	copied, err := dst.ListPrefixEntries("")
+	if err != nil {
+		return len(entries), fmt.Errorf("failed to verify target database: %w", err)
+	}
+	if len(copied) != len(entries) {
+		return len(entries), fmt.Errorf("verification failed: copied %d keys, target has %d", len(entries), len(copied))
+	}
+	for i := range entries {
+		if copied[i].Key != entries[i].Key || string(copied[i].Value) != string(entries[i].Value) {
+			return len(entries), fmt.Errorf("verification failed at key %q", entries[i].Key)
+		}
+	}
+
+	return len(entries), nil
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// SchemaVersion is the version of the key layout and record format
+// written by this build. Bump it when stored data changes shape.
+const SchemaVersion = 3
+
+// DB represents a key-value database on a pluggable storage driver
+type DB struct {
+	store  Store
+	driver string
+	path   string
+	badger *badger.DB // Set for the Badger driver, for backups and GC
+	ns     string     // Key prefix of a Namespace view
+	watch  *watchers  // Shared with Namespace views
+
+	batchLimit int // Writes per batched transaction; 0 uses batchWrites
+}
+
+// Config holds database configuration
+type Config struct {
+	Path   string // Database directory (Badger) or file (SQLite) path
+	Driver string // "badger" or "sqlite"; detected from Path when empty
+	Badger BadgerOptions
+
+	// BackupDir receives a snapshot before pending migrations are applied
+	// Defaults to a "pre-migrate" directory next to Path
+	BackupDir string
+
+	// SkipMigrations opens the database without migrating it,
+	// for tools that inspect or migrate explicitly
+	SkipMigrations bool
+}
+
+// NewDB creates a new database connection
+func NewDB(cfg Config) (*DB, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = DetectDriver(cfg.Path)
+	}
+
+	d := &DB{driver: driver, path: cfg.Path, watch: &watchers{}}
+	switch driver {
+	case DriverBadger:
+		bdb, err := openBadger(cfg.Path, cfg.Badger)
+		if err != nil {
+			return nil, err
+		}
+		d.store = &badgerStore{db: bdb}
+		d.badger = bdb
+		d.batchLimit = badgerBatchLimit(bdb)
+	case DriverSQLite:
+		store, err := openSQLite(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		d.store = store
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+
+	if !cfg.SkipMigrations {
+		backupDir := cfg.BackupDir
+		if backupDir == "" {
+			backupDir = filepath.Join(filepath.Dir(cfg.Path), "pre-migrate")
+		}
+		if _, err := d.Migrate(MigrateOptions{BackupDir: backupDir}); err != nil {
+			d.store.Close()
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Driver returns the storage driver name
+func (d *DB) Driver() string {
+	return d.driver
+}
+
+// IsNotFound reports whether err means the requested key does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// Close closes the database connection
+func (d *DB) Close() error {
+	return d.store.Close()
+}
+
+// Set stores a value by key
+func (d *DB) Set(key, value []byte) error {
+	return d.Update(func(txn *Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Get retrieves a value by key
+func (d *DB) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := d.View(func(txn *Txn) error {
+		var err error
+		val, err = txn.Get(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// Delete removes a key
+func (d *DB) Delete(key []byte) error {
+	return d.Update(func(txn *Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// SetJSON stores a JSON-encoded value
+func (d *DB) SetJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return d.Set([]byte(key), data)
+}
+
+// GetJSON retrieves and decodes a JSON value
+func (d *DB) GetJSON(key string, dest interface{}) error {
+	data, err := d.Get([]byte(key))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
+}
+
+// ListPrefix returns all keys with a given prefix
+func (d *DB) ListPrefix(prefix string) ([][]byte, error) {
+	var keys [][]byte
+	err := d.View(func(txn *Txn) error {
+		list, err := txn.ListPrefixKeys(prefix)
+		if err != nil {
+			return err
+		}
+		for _, k := range list {
+			keys = append(keys, []byte(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// ListPrefixJSON returns all JSON values with a given prefix
+func (d *DB) ListPrefixJSON(prefix string) ([]json.RawMessage, error) {
+	var values []json.RawMessage
+	err := d.View(func(txn *Txn) error {
+		entries, err := txn.ListPrefixEntries(prefix)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			values = append(values, json.RawMessage(e.Value))
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Entry is a key/value pair returned by prefix scans
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// ListPrefixEntries returns all keys and values with a given prefix
+// An empty prefix returns every entry in the database
+func (d *DB) ListPrefixEntries(prefix string) ([]Entry, error) {
+	var entries []Entry
+	err := d.View(func(txn *Txn) error {
+		var err error
+		entries, err = txn.ListPrefixEntries(prefix)
+		return err
+	})
+	return entries, err
+}
+
+// IsEmpty reports whether the database holds no data
+// Internal records under MetaPrefix, such as the schema version, are ignored
+func (d *DB) IsEmpty() (bool, error) {
+	empty := true
+	err := d.store.View(func(txn StoreTxn) error {
+		return txn.Iterate(nil, nil, nil, true, func(key, _ []byte) error {
+			if !strings.HasPrefix(string(key), MetaPrefix) {
+				empty = false
+				return errStopIteration
+			}
+			return nil
+		})
+	})
+	return empty, err
+}
+
+// RunGC manually triggers garbage collection
+// Call this periodically to reclaim disk space
+func (d *DB) RunGC() error {
+	if d.badger == nil {
+		return ErrNotSupported
+	}
+	return d.badger.RunValueLogGC(0.5)
+}
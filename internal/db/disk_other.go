@@ -0,0 +1,10 @@
+//go:build !unix
+
+package db
+
+import "io/fs"
+
+// allocatedSize returns the apparent file size where block counts are unavailable
+func allocatedSize(info fs.FileInfo) int64 {
+	return info.Size()
+}
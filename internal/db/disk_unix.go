@@ -0,0 +1,18 @@
+//go:build unix
+
+package db
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize returns the bytes actually allocated for a file
+// Badger preallocates sparse value log files, so the apparent size
+// greatly overstates disk usage
+func allocatedSize(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return info.Size()
+}
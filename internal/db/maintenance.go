@@ -0,0 +1,161 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// GCResult reports the outcome of a value log GC run
+type GCResult struct {
+	Rewrites   int   // Value log files rewritten
+	DiskBefore int64 // Bytes on disk before GC
+	DiskAfter  int64 // Bytes on disk after GC
+}
+
+// RunGCLoop runs value log GC until no more files can be rewritten
+// Badger rewrites at most one file per call, so a single RunGC rarely
+// reclaims everything. On SQLite this runs VACUUM instead.
+func (d *DB) RunGCLoop(discardRatio float64) (GCResult, error) {
+	var res GCResult
+	var err error
+	if res.DiskBefore, err = d.DiskUsage(); err != nil {
+		return res, err
+	}
+
+	if sqlite, ok := d.store.(*sqliteStore); ok {
+		if _, err := sqlite.db.Exec(`VACUUM`); err != nil {
+			return res, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	} else {
+		for {
+			err := d.badger.RunValueLogGC(discardRatio)
+			if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+				break
+			}
+			if err != nil {
+				return res, fmt.Errorf("value log GC failed: %w", err)
+			}
+			res.Rewrites++
+		}
+	}
+
+	res.DiskAfter, err = d.DiskUsage()
+	return res, err
+}
+
+// DiskUsage returns the bytes used by the database on disk
+func (d *DB) DiskUsage() (int64, error) {
+	if d.driver == DriverSQLite {
+		var total int64
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			info, err := os.Stat(d.path + suffix)
+			if err == nil {
+				total += allocatedSize(info)
+			}
+		}
+		return total, nil
+	}
+
+	var total int64
+	err := filepath.WalkDir(d.path, func(_ string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		total += allocatedSize(info)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure database size: %w", err)
+	}
+	return total, nil
+}
+
+// Stats describes the size and contents of a database
+type Stats struct {
+	Driver    string
+	Path      string
+	DiskUsage int64
+	LSMSize   int64 // Badger only
+	VLogSize  int64 // Badger only
+	Keys      int
+	Keyspaces []KeyspaceStats
+	Schema    int
+}
+
+// KeyspaceStats counts keys sharing their first key segment, e.g. "manga"
+type KeyspaceStats struct {
+	Name  string
+	Keys  int
+	Bytes int64 // Key and value bytes
+}
+
+// Stats collects size statistics
+func (d *DB) Stats() (Stats, error) {
+	st := Stats{Driver: d.driver, Path: d.path}
+
+	var err error
+	if st.DiskUsage, err = d.DiskUsage(); err != nil {
+		return st, err
+	}
+	if d.badger != nil {
+		st.LSMSize, st.VLogSize = d.badger.Size()
+	}
+	if st.Schema, err = d.SchemaVersionOf(); err != nil {
+		return st, err
+	}
+
+	spaces := make(map[string]*KeyspaceStats)
+	err = d.store.View(func(txn StoreTxn) error {
+		return txn.Iterate(nil, nil, nil, false, func(key, value []byte) error {
+			name, _, _ := strings.Cut(string(key), ":")
+			ks, ok := spaces[name]
+			if !ok {
+				ks = &KeyspaceStats{Name: name}
+				spaces[name] = ks
+			}
+			ks.Keys++
+			ks.Bytes += int64(len(key) + len(value))
+			st.Keys++
+			return nil
+		})
+	})
+	if err != nil {
+		return st, fmt.Errorf("failed to scan database: %w", err)
+	}
+
+	for _, ks := range spaces {
+		st.Keyspaces = append(st.Keyspaces, *ks)
+	}
+	sort.Slice(st.Keyspaces, func(i, j int) bool {
+		return st.Keyspaces[i].Name < st.Keyspaces[j].Name
+	})
+	return st, nil
+}
+
+// FormatSize formats a byte count for display, e.g. "12.3 MiB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MetaPrefix is the keyspace for internal bookkeeping records
+const MetaPrefix = "meta:"
+
+// schemaVersionKey holds the schema version of the stored data
+const schemaVersionKey = MetaPrefix + "schema_version"
+
+// schemaRecord is the stored schema version
+type schemaRecord struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Migration upgrades stored data from Version-1 to Version
+// Up is called for the top level and for every namespace, and may commit
+// in several transactions (see UpdateEach) so large databases stay within
+// the storage driver's transaction limits. The version is recorded only
+// after Up has succeeded everywhere, so an interrupted migration runs
+// again from the start: Up must be safe to repeat on partly migrated data.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(d *DB) error
+}
+
+// migrations holds the registered migrations
+var migrations []Migration
+
+// RegisterMigration adds a migration to the ordered list run at startup
+// Packages that own stored data register their migrations from init
+func RegisterMigration(m Migration) {
+	for _, existing := range migrations {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("db: duplicate migration version %d (%s, %s)", m.Version, existing.Name, m.Name))
+		}
+	}
+	migrations = append(migrations, m)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// MigrateOptions controls how pending migrations are applied
+type MigrateOptions struct {
+	DryRun    bool   // Run migrations on an in-memory copy
+	BackupDir string // Write a snapshot here before migrating; empty skips it
+}
+
+// MigrationReport summarizes a migration run
+type MigrationReport struct {
+	From    int
+	To      int
+	Applied []Migration
+	Backup  string // Snapshot written before migrating
+}
+
+// SchemaVersionOf returns the stored schema version
+// Databases created before versioning are treated as version 1
+func (d *DB) SchemaVersionOf() (int, error) {
+	version := 0
+	err := d.View(func(txn *Txn) error {
+		var err error
+		version, err = readSchemaVersion(txn)
+		return err
+	})
+	return version, err
+}
+
+func readSchemaVersion(txn *Txn) (int, error) {
+	var rec schemaRecord
+	err := txn.GetJSON(schemaVersionKey, &rec)
+	if IsNotFound(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return rec.Version, nil
+}
+
+// SetSchemaVersion records the schema version of the stored data
+// Used after restoring data written by another version, before Migrate
+func (d *DB) SetSchemaVersion(version int) error {
+	return d.Update(func(txn *Txn) error {
+		return writeSchemaVersion(txn, version)
+	})
+}
+
+func writeSchemaVersion(txn *Txn, version int) error {
+	return txn.SetJSON(schemaVersionKey, schemaRecord{Version: version, UpdatedAt: time.Now().UTC()})
+}
+
+// Migrate applies pending migrations up to SchemaVersion
+// A fresh database is stamped with the current version without running
+// migrations. A database newer than this build is rejected.
+func (d *DB) Migrate(opts MigrateOptions) (*MigrationReport, error) {
+	if err := checkMigrations(); err != nil {
+		return nil, err
+	}
+
+	from, err := d.SchemaVersionOf()
+	if err != nil {
+		return nil, err
+	}
+	report := &MigrationReport{From: from, To: from}
+
+	if from > SchemaVersion {
+		return report, fmt.Errorf("database schema version %d is newer than supported version %d; upgrade komikan", from, SchemaVersion)
+	}
+
+	empty, err := d.IsEmpty()
+	if err != nil {
+		return report, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if empty {
+		report.To = SchemaVersion
+		if opts.DryRun {
+			return report, nil
+		}
+		return report, d.Update(func(txn *Txn) error {
+			return writeSchemaVersion(txn, SchemaVersion)
+		})
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > from {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	if opts.DryRun {
+		// Migrations commit as they go, so run them on a throwaway copy
+		scratch, err := openMemory()
+		if err != nil {
+			return report, err
+		}
+		defer scratch.Close()
+		if _, err := CopyAll(scratch, d); err != nil {
+			return report, fmt.Errorf("failed to copy database for dry run: %w", err)
+		}
+		return report, scratch.applyMigrations(pending, report)
+	}
+
+	if opts.BackupDir != "" {
+		path, err := d.WriteSnapshot(opts.BackupDir)
+		if err != nil {
+			return report, fmt.Errorf("failed to back up before migrating: %w", err)
+		}
+		report.Backup = path
+	}
+
+	return report, d.applyMigrations(pending, report)
+}
+
+// applyMigrations runs migrations in order, recording each version once
+// the migration has completed
+func (d *DB) applyMigrations(pending []Migration, report *MigrationReport) error {
+	for _, m := range pending {
+		if err := m.apply(d); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		err := d.Update(func(txn *Txn) error {
+			return writeSchemaVersion(txn, m.Version)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		report.Applied = append(report.Applied, m)
+		report.To = m.Version
+	}
+	return nil
+}
+
+// apply runs the migration on the top level and in every namespace
+func (m Migration) apply(d *DB) error {
+	if err := m.Up(d); err != nil {
+		return err
+	}
+	names, err := d.Namespaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := m.Up(d.Namespace(name)); err != nil {
+			return fmt.Errorf("namespace %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkMigrations verifies that registered migrations form a gapless
+// sequence from version 2 up to SchemaVersion
+func checkMigrations() error {
+	expected := 2
+	for _, m := range migrations {
+		if m.Version != expected {
+			return fmt.Errorf("db: migration %d (%s) out of sequence, expected version %d", m.Version, m.Name, expected)
+		}
+		expected++
+	}
+	if expected-1 != SchemaVersion {
+		return fmt.Errorf("db: SchemaVersion is %d but migrations end at %d", SchemaVersion, expected-1)
+	}
+	return nil
+}
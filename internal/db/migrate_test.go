@@ -0,0 +1,192 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// setMigrations replaces the registered migrations for one test
+func setMigrations(t *testing.T, ms ...Migration) {
+	t.Helper()
+	saved := migrations
+	migrations = ms
+	t.Cleanup(func() { migrations = saved })
+}
+
+// testMigrations upgrades books to SchemaVersion: version 2 prefixes
+// every series, version 3 rebuilds the indexes. fail, when set, is
+// consulted before each record of version 2.
+func testMigrations(fail func(id string) error) []Migration {
+	return []Migration{
+		{Version: 2, Name: "prefix series", Up: func(d *DB) error {
+			return d.UpdateEach(testBooks.codec.Prefix(), func(txn *Txn, e Entry) error {
+				var b testBook
+				if err := json.Unmarshal(e.Value, &b); err != nil {
+					return err
+				}
+				if fail != nil {
+					if err := fail(b.ID); err != nil {
+						return err
+					}
+				}
+				if len(b.Series) > 2 && b.Series[:2] == "s:" {
+					return nil // Already migrated by an interrupted run
+				}
+				b.Series = "s:" + b.Series
+				return txn.SetJSON(e.Key, b)
+			})
+		}},
+		{Version: 3, Name: "rebuild indexes", Up: testBooks.Reindex},
+	}
+}
+
+// seedV1 writes books in the version 1 layout, without index entries
+func seedV1(t *testing.T, d *DB, books []testBook) {
+	t.Helper()
+	for _, db := range []*DB{d, d.Namespace("member")} {
+		for start := 0; start < len(books); start += 500 {
+			err := db.Update(func(txn *Txn) error {
+				for _, b := range books[start:min(start+500, len(books))] {
+					if err := txn.SetJSON(testBooks.Key(b.ID), b); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := d.SetSchemaVersion(1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkMigrated(t *testing.T, d *DB, n int) {
+	t.Helper()
+	if v, err := d.SchemaVersionOf(); err != nil || v != SchemaVersion {
+		t.Fatalf("schema version = %d, %v; want %d", v, err, SchemaVersion)
+	}
+	for _, db := range []*DB{d, d.Namespace("member")} {
+		if got := lookup(t, db, testBySeries, "s:A"); len(got) != n {
+			t.Errorf("%q: %d books indexed under s:A, want %d", db.ns, len(got), n)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	ran := false
+	setMigrations(t,
+		Migration{Version: 2, Name: "two", Up: func(*DB) error { ran = true; return nil }},
+		Migration{Version: 3, Name: "three", Up: func(*DB) error { ran = true; return nil }},
+	)
+	d := openTestDB(t, DriverBadger, BadgerOptions{})
+
+	report, err := d.Migrate(MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran || len(report.Applied) != 0 || report.To != SchemaVersion {
+		t.Errorf("fresh database: ran=%v report=%+v; want stamped without migrating", ran, report)
+	}
+}
+
+func TestMigrateEveryNamespace(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			setMigrations(t, testMigrations(nil)...)
+			d := openTestDB(t, driver, BadgerOptions{})
+			seedV1(t, d, []testBook{{ID: "1", Series: "A"}, {ID: "2", Series: "A"}})
+
+			report, err := d.Migrate(MigrateOptions{BackupDir: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.From != 1 || report.To != SchemaVersion || len(report.Applied) != 2 || report.Backup == "" {
+				t.Errorf("report = %+v", report)
+			}
+			checkMigrated(t, d, 2)
+		})
+	}
+}
+
+func TestMigrateResumesAfterFailure(t *testing.T) {
+	books := manyBooks(1500)
+	for i := range books {
+		books[i].Series = "A"
+	}
+	crash := errors.New("crash")
+
+	setMigrations(t, testMigrations(func(id string) error {
+		if id == "01000" {
+			return crash
+		}
+		return nil
+	})...)
+	d := openTestDB(t, DriverBadger, BadgerOptions{})
+	d.batchLimit = 100
+	seedV1(t, d, books)
+
+	if _, err := d.Migrate(MigrateOptions{}); !errors.Is(err, crash) {
+		t.Fatalf("Migrate = %v, want the crash", err)
+	}
+	if v, _ := d.SchemaVersionOf(); v != 1 {
+		t.Fatalf("schema version after a failed migration = %d, want 1", v)
+	}
+
+	setMigrations(t, testMigrations(nil)...)
+	if _, err := d.Migrate(MigrateOptions{}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	checkMigrated(t, d, 1500)
+}
+
+func TestMigrateLargeDatabase(t *testing.T) {
+	books := manyBooks(3000)
+	for i := range books {
+		books[i].Series = "A"
+	}
+	setMigrations(t, testMigrations(nil)...)
+	d := openTestDB(t, DriverBadger, BadgerOptions{MemTableSizeMB: 8})
+	seedV1(t, d, books)
+
+	if _, err := d.Migrate(MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	checkMigrated(t, d, 3000)
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	setMigrations(t, testMigrations(nil)...)
+	d := openTestDB(t, DriverSQLite, BadgerOptions{})
+	seedV1(t, d, []testBook{{ID: "1", Series: "A"}})
+
+	report, err := d.Migrate(MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.To != SchemaVersion || len(report.Applied) != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	if v, _ := d.SchemaVersionOf(); v != 1 {
+		t.Errorf("dry run changed the schema version to %d", v)
+	}
+	var b testBook
+	if err := d.GetJSON(testBooks.Key("1"), &b); err != nil || b.Series != "A" {
+		t.Errorf("dry run changed the data: %+v, %v", b, err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	setMigrations(t, testMigrations(nil)...)
+	d := openTestDB(t, DriverBadger, BadgerOptions{})
+	seedV1(t, d, []testBook{{ID: "1"}})
+	if err := d.SetSchemaVersion(SchemaVersion + 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Migrate(MigrateOptions{}); err == nil {
+		t.Fatal("Migrate accepted a newer schema")
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import "strings"
+
+// NamespacePrefix is the keyspace holding namespaced data sets
+// A key "k" in namespace "n" is stored as "ns:n:k"
+const NamespacePrefix = "ns:"
+
+// Namespace returns a view of the database in which every key lives under
+// its own prefix, so collections and indexes work unchanged on an
+// independent data set. name must not contain ":".
+// Migrations and RebuildIndexes cover every namespace. The view shares
+// the parent's connection; only close the parent.
+func (d *DB) Namespace(name string) *DB {
+	view := *d
+	view.ns = d.ns + NamespacePrefix + name + ":"
+	return &view
+}
+
+// Namespaces returns the names of the namespaces holding data
+func (d *DB) Namespaces() ([]string, error) {
+	var names []string
+	err := d.View(func(txn *Txn) error {
+		var err error
+		names, err = txn.namespaces()
+		return err
+	})
+	return names, err
+}
+
+func (t *Txn) namespaces() ([]string, error) {
+	keys, err := t.ListPrefixKeys(NamespacePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, k := range keys {
+		name, _, _ := strings.Cut(strings.TrimPrefix(k, NamespacePrefix), ":")
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// IsIndexKey reports whether a raw storage key is a secondary index entry,
+// at the top level or inside a namespace
+func IsIndexKey(key string) bool {
+	for strings.HasPrefix(key, NamespacePrefix) {
+		_, rest, ok := strings.Cut(strings.TrimPrefix(key, NamespacePrefix), ":")
+		if !ok {
+			return false
+		}
+		key = rest
+	}
+	return strings.HasPrefix(key, IndexPrefix)
+}
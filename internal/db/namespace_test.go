@@ -0,0 +1,60 @@
+package db
+
+import "testing"
+
+func TestNamespacesAreIsolated(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			alice, bob := d.Namespace("alice"), d.Namespace("bob")
+			putBooks(t, d, testBook{ID: "1", Series: "top"})
+			putBooks(t, alice, testBook{ID: "1", Series: "alice"}, testBook{ID: "2", Series: "alice"})
+			putBooks(t, bob, testBook{ID: "1", Series: "bob"})
+
+			for _, tc := range []struct {
+				db    *DB
+				count int
+			}{{d, 1}, {alice, 2}, {bob, 1}} {
+				err := tc.db.View(func(txn *Txn) error {
+					page, err := testBooks.Scan(txn, Query[testBook]{})
+					if err != nil {
+						return err
+					}
+					if page.Total != tc.count {
+						t.Errorf("%q holds %d books, want %d", tc.db.ns, page.Total, tc.count)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := lookup(t, alice, testBySeries, "bob"); len(got) != 0 {
+				t.Errorf("alice's index sees bob's books: %v", got)
+			}
+
+			names, err := d.Namespaces()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(names) != 2 || names[0] != "alice" || names[1] != "bob" {
+				t.Errorf("Namespaces = %v", names)
+			}
+		})
+	}
+}
+
+func TestIsIndexKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"idx:manga:series:A\x00isbn":          true,
+		"ns:alice:idx:manga:series:A\x00isbn": true,
+		"ns:a:ns:b:idx:x":                     true,
+		"manga:isbn:978":                      false,
+		"ns:alice:manga:isbn:978":             false,
+		"ns:broken":                           false,
+	} {
+		if got := IsIndexKey(key); got != want {
+			t.Errorf("IsIndexKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver
+)
+
+// sqliteStore implements Store on a single SQLite table
+// Keys are BLOBs, which SQLite compares bytewise like Badger
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// sqliteURI returns a file: URI for path with the given query
+// The path is escaped, so names containing '?', '#' or '%' open the right
+// file, and made absolute, since a relative one would be read as a host.
+func sqliteURI(path, query string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: query}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path // Windows drive letter
+	}
+	return u.String(), nil
+}
+
+// openSQLite opens or creates a SQLite database file
+func openSQLite(path string) (*sqliteStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	dsn, err := sqliteURI(path, "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// A single connection serializes writers and avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS kv (
+		key   BLOB PRIMARY KEY,
+		value BLOB NOT NULL
+	) WITHOUT ROWID`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Update(fn func(txn StoreTxn) error) error {
+	return s.run(false, fn)
+}
+
+func (s *sqliteStore) View(fn func(txn StoreTxn) error) error {
+	return s.run(true, fn)
+}
+
+func (s *sqliteStore) run(readOnly bool, fn func(txn StoreTxn) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(sqliteTxn{tx: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if readOnly {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// openSQLiteSnapshot opens a snapshot file read-only and checks its integrity
+// It is opened immutable, so no journal files appear next to it.
+func openSQLiteSnapshot(path string) (*sqliteStore, error) {
+	dsn, err := sqliteURI(path, "mode=ro&immutable=1")
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to check snapshot: %w", err)
+	}
+	if result != "ok" {
+		db.Close()
+		return nil, fmt.Errorf("snapshot is corrupt: %s", result)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// snapshot writes a consistent copy of the database to path
+func (s *sqliteStore) snapshot(path string) error {
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// sqliteTxn implements StoreTxn on a SQL transaction
+type sqliteTxn struct {
+	tx *sql.Tx
+}
+
+func (t sqliteTxn) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := t.tx.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return val, err
+}
+
+func (t sqliteTxn) Set(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := t.tx.Exec(`INSERT INTO kv (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func (t sqliteTxn) Delete(key []byte) error {
+	_, err := t.tx.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (t sqliteTxn) Iterate(prefix, start, end []byte, keysOnly bool, fn func(key, value []byte) error) error {
+	// Clamp the range to the prefix. A nil start would bind as NULL and
+	// match no key at all.
+	if string(start) < string(prefix) {
+		start = prefix
+	}
+	if start == nil {
+		start = []byte{}
+	}
+	if upper := prefixEnd(prefix); upper != nil && (len(end) == 0 || string(end) > string(upper)) {
+		end = upper
+	}
+
+	column := "value"
+	if keysOnly {
+		column = "NULL"
+	}
+	query := `SELECT key, ` + column + ` FROM kv WHERE key >= ?`
+	args := []interface{}{start}
+	if len(end) > 0 {
+		query += ` AND key < ?`
+		args = append(args, end)
+	}
+	query += ` ORDER BY key`
+
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, val []byte
+		if err := rows.Scan(&key, &val); err != nil {
+			return err
+		}
+		if err := fn(key, val); err != nil {
+			if errors.Is(err, errStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// prefixEnd returns the smallest key greater than every key with prefix,
+// or nil if there is none (empty or all-0xff prefix)
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
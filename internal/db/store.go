@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// Driver names accepted by Config.Driver
+const (
+	DriverBadger = "badger"
+	DriverSQLite = "sqlite"
+)
+
+// ErrNotFound is returned when a key does not exist
+var ErrNotFound = errors.New("key not found")
+
+// ErrNotSupported is returned for operations the storage driver lacks,
+// such as Badger streaming backups on SQLite
+var ErrNotSupported = errors.New("not supported by this storage driver")
+
+// Store is an ordered, transactional key-value backend
+// Keys are compared bytewise, so prefix and range scans behave the same
+// on every driver
+type Store interface {
+	Update(fn func(txn StoreTxn) error) error
+	View(fn func(txn StoreTxn) error) error
+	Close() error
+}
+
+// StoreTxn is a transaction on a Store
+type StoreTxn interface {
+	Get(key []byte) ([]byte, error) // Returns ErrNotFound for missing keys
+	Set(key, value []byte) error
+	Delete(key []byte) error
+
+	// Iterate calls fn for keys with prefix in [start, end), in key order
+	// An empty end means the end of the prefix. Values are nil when
+	// keysOnly is set. Returning errStopIteration from fn ends the scan.
+	Iterate(prefix, start, end []byte, keysOnly bool, fn func(key, value []byte) error) error
+}
+
+// errStopIteration ends an Iterate call early without an error
+var errStopIteration = errors.New("stop iteration")
+
+// DetectDriver picks a driver for an existing path
+// A directory is a Badger database and a file is SQLite. New paths
+// ending in .sqlite or .sqlite3 use SQLite; anything else uses Badger.
+func DetectDriver(path string) string {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return DriverBadger
+		}
+		return DriverSQLite
+	}
+	if strings.HasSuffix(path, ".sqlite") || strings.HasSuffix(path, ".sqlite3") {
+		return DriverSQLite
+	}
+	return DriverBadger
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// drivers lists the storage drivers every store test runs against
+var drivers = []string{DriverBadger, DriverSQLite}
+
+// openTestDB opens an empty database without running migrations
+func openTestDB(t *testing.T, driver string, tune BadgerOptions) *DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "komikan.db")
+	if driver == DriverSQLite {
+		path += ".sqlite"
+	}
+	d, err := NewDB(Config{Path: path, Driver: driver, Badger: tune, SkipMigrations: true})
+	if err != nil {
+		t.Fatalf("NewDB(%s): %v", driver, err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestStoreGetSetDelete(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+
+			if _, err := d.Get([]byte("missing")); !IsNotFound(err) {
+				t.Fatalf("Get(missing) = %v, want ErrNotFound", err)
+			}
+			if err := d.Set([]byte("k"), []byte("v1")); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Set([]byte("k"), []byte("v2")); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := d.Get([]byte("k")); err != nil || string(v) != "v2" {
+				t.Fatalf("Get(k) = %q, %v; want v2", v, err)
+			}
+			if err := d.Delete([]byte("k")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Get([]byte("k")); !IsNotFound(err) {
+				t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStoreRollback(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+
+			err := d.Update(func(txn *Txn) error {
+				if err := txn.Set([]byte("k"), []byte("v")); err != nil {
+					return err
+				}
+				return ErrNotSupported
+			})
+			if err != ErrNotSupported {
+				t.Fatalf("Update = %v, want the error from fn", err)
+			}
+			if _, err := d.Get([]byte("k")); !IsNotFound(err) {
+				t.Fatalf("write of a failed transaction was committed")
+			}
+		})
+	}
+}
+
+func TestStoreKeyOrder(t *testing.T) {
+	keys := []string{"a:", "a:\x00z", "a:a", "a:b\x00", "a:b\x00c", "a:\xff", "b:"}
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			for _, k := range slices.Backward(keys) {
+				if err := d.Set([]byte(k), []byte("v")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := d.View(func(txn *Txn) error {
+				got, err := txn.ListPrefixKeys("a:")
+				if err != nil {
+					return err
+				}
+				if want := keys[:6]; !slices.Equal(got, want) {
+					t.Errorf("ListPrefixKeys = %q, want %q", got, want)
+				}
+
+				got, err = txn.ListRangeKeys("a:", "a:a", "a:b\x00c")
+				if err != nil {
+					return err
+				}
+				if want := []string{"a:a", "a:b\x00"}; !slices.Equal(got, want) {
+					t.Errorf("ListRangeKeys = %q, want %q", got, want)
+				}
+
+				entries, err := txn.listFrom("a:", "a:a", 2)
+				if err != nil {
+					return err
+				}
+				if len(entries) != 2 || entries[0].Key != "a:b\x00" || entries[1].Key != "a:b\x00c" {
+					t.Errorf("listFrom = %q, want the two keys after a:a", entries)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestIsEmptyIgnoresMeta(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			d := openTestDB(t, driver, BadgerOptions{})
+			if err := d.SetSchemaVersion(SchemaVersion); err != nil {
+				t.Fatal(err)
+			}
+			if empty, err := d.IsEmpty(); err != nil || !empty {
+				t.Fatalf("IsEmpty with only meta = %v, %v; want true", empty, err)
+			}
+			if err := d.Set([]byte("x"), nil); err != nil {
+				t.Fatal(err)
+			}
+			if empty, err := d.IsEmpty(); err != nil || empty {
+				t.Fatalf("IsEmpty with data = %v, %v; want false", empty, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Txn is a read or read-write transaction spanning multiple keys
+type Txn struct {
+	txn     StoreTxn
+	ns      string    // Prepended to every key, see DB.Namespace
+	changes *[]string // Keys written, reported to OnChange after commit
+	limit   int       // Writes per batch, see Full
+}
+
+// Update runs fn in a read-write transaction
+// All writes are committed together, or none if fn returns an error
+func (d *DB) Update(fn func(txn *Txn) error) error {
+	var changes []string
+	err := d.store.Update(func(txn StoreTxn) error {
+		changes = changes[:0]
+		return fn(&Txn{txn: txn, ns: d.ns, changes: &changes, limit: d.batchLimit})
+	})
+	if err == nil && d.watch != nil {
+		d.watch.notify(changes)
+	}
+	return err
+}
+
+// View runs fn in a read-only transaction
+func (d *DB) View(fn func(txn *Txn) error) error {
+	return d.store.View(func(txn StoreTxn) error {
+		return fn(&Txn{txn: txn, ns: d.ns})
+	})
+}
+
+// Get retrieves a value by key
+func (t *Txn) Get(key []byte) ([]byte, error) {
+	return t.txn.Get(t.key(key))
+}
+
+// Set stores a value by key
+func (t *Txn) Set(key, value []byte) error {
+	k := t.key(key)
+	t.changed(k)
+	return t.txn.Set(k, value)
+}
+
+// Delete removes a key
+func (t *Txn) Delete(key []byte) error {
+	k := t.key(key)
+	t.changed(k)
+	return t.txn.Delete(k)
+}
+
+// SetJSON stores a JSON-encoded value
+func (t *Txn) SetJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return t.Set([]byte(key), data)
+}
+
+// GetJSON retrieves and decodes a JSON value
+func (t *Txn) GetJSON(key string, dest interface{}) error {
+	data, err := t.Get([]byte(key))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
+}
+
+// ListPrefixEntries returns all keys and values with a given prefix
+func (t *Txn) ListPrefixEntries(prefix string) ([]Entry, error) {
+	var entries []Entry
+	p := []byte(t.ns + prefix)
+	err := t.txn.Iterate(p, p, nil, false, func(key, value []byte) error {
+		entries = append(entries, Entry{Key: string(key[len(t.ns):]), Value: value})
+		return nil
+	})
+	return entries, err
+}
+
+// ListPrefixKeys returns all keys with a given prefix without reading values
+func (t *Txn) ListPrefixKeys(prefix string) ([]string, error) {
+	return t.ListRangeKeys(prefix, prefix, "")
+}
+
+// ListRangeKeys returns keys with a given prefix in [start, end)
+// An empty end means the end of the prefix
+func (t *Txn) ListRangeKeys(prefix, start, end string) ([]string, error) {
+	if end != "" {
+		end = t.ns + end
+	}
+	var keys []string
+	err := t.txn.Iterate([]byte(t.ns+prefix), []byte(t.ns+start), []byte(end), true, func(key, _ []byte) error {
+		keys = append(keys, string(key[len(t.ns):]))
+		return nil
+	})
+	return keys, err
+}
+
+func (t *Txn) changed(key []byte) {
+	if t.changes != nil {
+		*t.changes = append(*t.changes, string(key))
+	}
+}
+
+// key returns the storage key for a key within the transaction's namespace
+func (t *Txn) key(key []byte) []byte {
+	if t.ns == "" {
+		return key
+	}
+	return append([]byte(t.ns), key...)
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"strings"
+	"sync"
+)
+
+// watchers are the change callbacks shared by a database and its views
+type watchers struct {
+	mu  sync.Mutex
+	fns []func(keys []string)
+}
+
+// OnChange registers fn to be called after each committed transaction
+// with the keys it wrote or deleted. Keys are storage keys, including
+// any namespace prefix (see SplitNamespace). fn runs on the writer's
+// goroutine, so it should return quickly.
+func (d *DB) OnChange(fn func(keys []string)) {
+	d.watch.mu.Lock()
+	defer d.watch.mu.Unlock()
+	d.watch.fns = append(d.watch.fns, fn)
+}
+
+func (w *watchers) notify(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	w.mu.Lock()
+	fns := w.fns
+	w.mu.Unlock()
+	for _, fn := range fns {
+		fn(keys)
+	}
+}
+
+// SplitNamespace splits a storage key into the name of its innermost
+// namespace, empty at the top level, and the key within it
+func SplitNamespace(key string) (ns, rest string) {
+	rest = key
+	for strings.HasPrefix(rest, NamespacePrefix) {
+		name, inner, ok := strings.Cut(strings.TrimPrefix(rest, NamespacePrefix), ":")
+		if !ok {
+			break
+		}
+		ns, rest = name, inner
+	}
+	return ns, rest
+}
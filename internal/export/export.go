@@ -0,0 +1,282 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+// Format identifies komikan export files
+const Format = "komikan-export"
+
+// Header is the first line of a JSON Lines export
+type Header struct {
+	Type          string    `json:"type"` // Always "header"
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Records       int       `json:"records"`
+}
+
+// Record is a single key/value line of a JSON Lines export
+// Values are stored as JSON so the file can be read without Badger
+type Record struct {
+	Type  string          `json:"type"` // Always "record"
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ExportReport summarizes a JSON Lines export
+type ExportReport struct {
+	Records int
+	Skipped []string // Keys left out because their values are not JSON
+}
+
+// WriteJSONL writes every record in the database as JSON Lines
+// The export covers all keyspaces (manga, series and any other data),
+// so a restore reproduces the database exactly. Internal meta records
+// are represented by the header instead, and index entries are rebuilt
+// on restore. Values that are not JSON cannot be written as a Record;
+// they are left out and their keys reported rather than failing the
+// whole export.
+func WriteJSONL(w io.Writer, database *db.DB) (*ExportReport, error) {
+	all, err := database.ListPrefixEntries("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	version, err := database.SchemaVersionOf()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ExportReport{}
+	entries := make([]db.Entry, 0, len(all))
+	for _, e := range all {
+		if strings.HasPrefix(e.Key, db.MetaPrefix) || db.IsIndexKey(e.Key) {
+			continue
+		}
+		if !json.Valid(e.Value) {
+			report.Skipped = append(report.Skipped, e.Key)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	header := Header{
+		Type:          "header",
+		Format:        Format,
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		Records:       len(entries),
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := enc.Encode(Record{Type: "record", Key: e.Key, Value: e.Value}); err != nil {
+			return nil, fmt.Errorf("failed to write record %s: %w", e.Key, err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush export: %w", err)
+	}
+	report.Records = len(entries)
+	return report, nil
+}
+
+// csvHeader lists the columns of the manga CSV export
+var csvHeader = []string{"isbn", "title", "series", "volume", "author", "publisher", "publish_date", "url", "tags", "status", "notes"}
+
+// WriteCSV writes the manga collection as CSV for spreadsheets
+// CSV is a read-only view of the manga records only; reading logs,
+// loans and purchases are in the JSON Lines export.
+func WriteCSV(w io.Writer, mgr *manga.Manager) (int, error) {
+	list, err := mgr.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list manga: %w", err)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return 0, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, m := range list {
+		record := []string{
+			m.ISBN,
+			m.Title,
+			m.Series,
+			strconv.Itoa(m.Volume),
+			m.Author,
+			m.Publisher,
+			m.PublishDate,
+			m.URL,
+			strings.Join(m.Tags, ","),
+			m.Status,
+			m.Notes,
+		}
+		if err := cw.Write(record); err != nil {
+			return 0, fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return 0, fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return len(list), nil
+}
+
+// RestoreOptions controls how an export is applied to a database
+type RestoreOptions struct {
+	DryRun bool // Validate only, write nothing
+}
+
+// RestoreReport summarizes a restore
+type RestoreReport struct {
+	Header  Header
+	Written int
+}
+
+// Restore reads a JSON Lines export and writes its records to the database
+// The target must be empty. The whole export is read and validated before
+// anything is written, so a truncated or corrupt file leaves the database
+// untouched. Exports from an older schema version are migrated after
+// loading, so open the database with SkipMigrations.
+func Restore(r io.Reader, database *db.DB, opts RestoreOptions) (*RestoreReport, error) {
+	empty, err := database.IsEmpty()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if !empty {
+		return nil, fmt.Errorf("target database is not empty; restore into a fresh path")
+	}
+
+	report := &RestoreReport{}
+	records, err := readRecords(r, &report.Header)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		report.Written = len(records)
+		return report, nil
+	}
+
+	for len(records) > 0 {
+		err := database.Update(func(txn *db.Txn) error {
+			for len(records) > 0 && !txn.Full() {
+				if err := txn.Set([]byte(records[0].Key), records[0].Value); err != nil {
+					return fmt.Errorf("failed to write %s: %w", records[0].Key, err)
+				}
+				records = records[1:]
+				report.Written++
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Bring restored data up to the current schema
+	if err := database.SetSchemaVersion(report.Header.SchemaVersion); err != nil {
+		return report, fmt.Errorf("failed to record schema version: %w", err)
+	}
+	if _, err := database.Migrate(db.MigrateOptions{}); err != nil {
+		return report, fmt.Errorf("failed to migrate restored data: %w", err)
+	}
+	if err := database.RebuildIndexes(); err != nil {
+		return report, fmt.Errorf("failed to rebuild indexes: %w", err)
+	}
+
+	return report, nil
+}
+
+// readRecords reads a whole export, filling in header, and returns its records
+// It fails unless the header is valid and every record it lists is present.
+func readRecords(r io.Reader, header *Header) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var records []Record
+	seen := make(map[string]bool)
+	line := 0
+	headerRead := false
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if line == 1 {
+			// Editors on Windows may save the file with a byte order mark
+			data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		// The first non-blank line is the header
+		if !headerRead {
+			headerRead = true
+			if err := json.Unmarshal(data, header); err != nil {
+				return nil, fmt.Errorf("failed to parse header: %w", err)
+			}
+			if err := validateHeader(*header); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d: %w", line, err)
+		}
+		if rec.Type != "record" || rec.Key == "" {
+			return nil, fmt.Errorf("line %d: unexpected record type %q", line, rec.Type)
+		}
+		if strings.HasPrefix(rec.Key, db.MetaPrefix) {
+			return nil, fmt.Errorf("line %d: export cannot contain meta record %s", line, rec.Key)
+		}
+		if seen[rec.Key] {
+			return nil, fmt.Errorf("line %d: duplicate record %s", line, rec.Key)
+		}
+		seen[rec.Key] = true
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read export: %w", err)
+	}
+
+	if !headerRead {
+		return nil, fmt.Errorf("export is empty")
+	}
+	if len(records) != header.Records {
+		return nil, fmt.Errorf("export is truncated: header lists %d records, found %d", header.Records, len(records))
+	}
+	return records, nil
+}
+
+func validateHeader(h Header) error {
+	if h.Type != "header" || h.Format != Format {
+		return fmt.Errorf("not a komikan export (format %q)", h.Format)
+	}
+	if h.SchemaVersion > db.SchemaVersion {
+		return fmt.Errorf("export schema version %d is newer than supported version %d", h.SchemaVersion, db.SchemaVersion)
+	}
+	if h.SchemaVersion < 1 {
+		return fmt.Errorf("invalid schema version %d", h.SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+func openTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	// A small memtable keeps Badger's transaction limit low
+	d, err := db.NewDB(db.Config{
+		Path:           filepath.Join(t.TempDir(), "komikan.db"),
+		Badger:         db.BadgerOptions{MemTableSizeMB: 8},
+		SkipMigrations: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// testExport writes an export of n records
+func testExport(t *testing.T, n int) []byte {
+	t.Helper()
+	src := openTestDB(t)
+	if err := src.SetSchemaVersion(db.SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	for start := 0; start < n; start += 500 {
+		err := src.Update(func(txn *db.Txn) error {
+			for i := start; i < min(start+500, n); i++ {
+				value := fmt.Sprintf(`{"key":"%d","notes":"%0100d"}`, i, i)
+				if err := txn.Set(fmt.Appendf(nil, "test:%05d", i), []byte(value)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteJSONL(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreLargeExport(t *testing.T) {
+	const n = 20000
+	data := testExport(t, n)
+
+	dst := openTestDB(t)
+	report, err := Restore(bytes.NewReader(data), dst, RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Written != n {
+		t.Errorf("wrote %d records, want %d", report.Written, n)
+	}
+	keys, err := dst.ListPrefix("test:")
+	if err != nil || len(keys) != n {
+		t.Fatalf("restored %d keys, %v; want %d", len(keys), err, n)
+	}
+}
+
+func TestRestoreTruncatedExportWritesNothing(t *testing.T) {
+	data := testExport(t, 100)
+	lines := strings.SplitAfter(string(data), "\n")
+	truncated := strings.Join(lines[:50], "")
+
+	dst := openTestDB(t)
+	if _, err := Restore(strings.NewReader(truncated), dst, RestoreOptions{}); err == nil {
+		t.Fatal("restored a truncated export")
+	}
+	if empty, err := dst.IsEmpty(); err != nil || !empty {
+		t.Fatalf("database after failed restore: empty = %v, %v", empty, err)
+	}
+
+	// A corrupt record late in the file is caught before writing too
+	lines[len(lines)-2] = "{\"type\":\"record\",\n"
+	if _, err := Restore(strings.NewReader(strings.Join(lines, "")), dst, RestoreOptions{}); err == nil {
+		t.Fatal("restored a corrupt export")
+	}
+	if empty, err := dst.IsEmpty(); err != nil || !empty {
+		t.Fatalf("database after failed restore: empty = %v, %v", empty, err)
+	}
+}
+
+func TestRestoreRefusesNonEmptyDatabase(t *testing.T) {
+	data := testExport(t, 10)
+
+	dst := openTestDB(t)
+	if err := dst.Set([]byte("test:existing"), []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Restore(bytes.NewReader(data), dst, RestoreOptions{})
+	if err == nil || !strings.Contains(err.Error(), "fresh path") {
+		t.Fatalf("Restore into non-empty database = %v; want refusal", err)
+	}
+}
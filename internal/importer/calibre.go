@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // Reads Calibre's metadata.db
+)
+
+// opfPackage is the subset of an OPF package document that Calibre writes
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Publishers  []string `xml:"publisher"`
+		Subjects    []string `xml:"subject"`
+		Identifiers []struct {
+			Scheme string `xml:"scheme,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"identifier"`
+	} `xml:"metadata"`
+}
+
+// newCalibreOPFReader reads a single Calibre metadata.opf file
+func newCalibreOPFReader(opts Options) Reader {
+	return func(r io.Reader) ([]Row, error) {
+		row, err := parseOPF(r)
+		if err != nil {
+			return nil, err
+		}
+		row.Line = 1
+		return []Row{row}, nil
+	}
+}
+
+// readCalibreLibrary reads a Calibre library directory
+// metadata.db is the library's catalogue and is read when present. The
+// metadata.opf files Calibre keeps next to each book are the fallback,
+// for copies of a library without its database.
+func readCalibreLibrary(root string) ([]Row, error) {
+	dbPath := filepath.Join(root, "metadata.db")
+	if _, err := os.Stat(dbPath); err == nil {
+		return readCalibreDB(dbPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat %s: %w", dbPath, err)
+	}
+	return readCalibreOPFs(root)
+}
+
+// readCalibreOPFs reads every metadata.opf below root
+func readCalibreOPFs(root string) ([]Row, error) {
+	var rows []Row
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "metadata.opf" {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+
+		row, err := parseOPF(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		row.Line = len(rows) + 1
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no metadata.db or metadata.opf files found under %s", root)
+	}
+
+	return rows, nil
+}
+
+// readCalibreDB reads every book in a Calibre metadata.db
+// The database is opened read-only and immutable, so Calibre may keep
+// running; changes it has not yet written out are not seen.
+func readCalibreDB(path string) ([]Row, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	// Escaped so names containing '?', '#' or '%' open the right file
+	dsn := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: "mode=ro&immutable=1"}
+	if !strings.HasPrefix(dsn.Path, "/") {
+		dsn.Path = "/" + dsn.Path // Windows drive letter
+	}
+	conn, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer conn.Close()
+
+	var rows []Row
+	byID := make(map[int64]int) // Book id to index in rows
+	err = calibreQuery(conn, `SELECT id, title, COALESCE(isbn, '') FROM books ORDER BY id`, func(id int64, values []string) {
+		byID[id] = len(rows)
+		rows = append(rows, Row{Line: len(rows) + 1, Title: values[0], ISBN: NormalizeISBN(values[1])})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Identifiers take precedence over the legacy books.isbn column
+	queries := []struct {
+		query string
+		add   func(row *Row, value string)
+	}{
+		{`SELECT book, val FROM identifiers WHERE lower(type) = 'isbn' ORDER BY id`, func(row *Row, isbn string) {
+			row.ISBN = NormalizeISBN(isbn)
+		}},
+		{`SELECT l.book, a.name FROM books_authors_link l JOIN authors a ON a.id = l.author ORDER BY l.id`, func(row *Row, name string) {
+			row.Author = joinNonEmpty(row.Author, name)
+		}},
+		{`SELECT l.book, p.name FROM books_publishers_link l JOIN publishers p ON p.id = l.publisher ORDER BY l.id`, func(row *Row, name string) {
+			if row.Publisher == "" {
+				row.Publisher = name
+			}
+		}},
+		{`SELECT l.book, t.name FROM books_tags_link l JOIN tags t ON t.id = l.tag ORDER BY l.id`, func(row *Row, name string) {
+			row.Tags = append(row.Tags, name)
+		}},
+	}
+	for _, q := range queries {
+		err := calibreQuery(conn, q.query, func(id int64, values []string) {
+			if i, ok := byID[id]; ok {
+				q.add(&rows[i], values[0])
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no books found in %s", path)
+	}
+	return rows, nil
+}
+
+// calibreQuery runs a query whose first column is a book id and whose
+// other columns are text, calling fn for each result row
+func calibreQuery(conn *sql.DB, query string, fn func(id int64, values []string)) error {
+	result, err := conn.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read Calibre database: %w", err)
+	}
+	defer result.Close()
+
+	cols, err := result.Columns()
+	if err != nil {
+		return err
+	}
+	for result.Next() {
+		var id int64
+		values := make([]string, len(cols)-1)
+		dest := []any{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := result.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to read Calibre database: %w", err)
+		}
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		fn(id, values)
+	}
+	return result.Err()
+}
+
+func joinNonEmpty(list, name string) string {
+	if list == "" {
+		return name
+	}
+	return list + "/" + name
+}
+
+func parseOPF(r io.Reader) (Row, error) {
+	var pkg opfPackage
+	if err := xml.NewDecoder(r).Decode(&pkg); err != nil {
+		return Row{}, fmt.Errorf("failed to parse OPF: %w", err)
+	}
+
+	row := Row{
+		Title:     first(pkg.Metadata.Titles),
+		Author:    strings.Join(pkg.Metadata.Creators, "/"),
+		Publisher: first(pkg.Metadata.Publishers),
+		Tags:      pkg.Metadata.Subjects,
+	}
+	for _, id := range pkg.Metadata.Identifiers {
+		if strings.EqualFold(id.Scheme, "ISBN") {
+			row.ISBN = NormalizeISBN(id.Value)
+			break
+		}
+	}
+
+	return row, nil
+}
+
+func first(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(list[0])
+}
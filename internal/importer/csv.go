@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Mapping maps row fields (isbn, title, author, publisher, tags) to
+// source columns, given either as a header name or a 0-based column index
+type Mapping map[string]string
+
+// mappingFields lists the row fields that can be mapped
+var mappingFields = []string{"isbn", "title", "author", "publisher", "tags"}
+
+// defaultHeaders lists header names recognized when no mapping is given
+var defaultHeaders = map[string][]string{
+	"isbn":      {"isbn", "isbn13", "isbn10", "13桁isbn", "isbn/asin", "jan"},
+	"title":     {"title", "タイトル", "書名", "作品名"},
+	"author":    {"author", "authors", "著者", "著者名", "作者", "作者名"},
+	"publisher": {"publisher", "出版社", "出版社名"},
+	"tags":      {"tags", "tag", "タグ"},
+}
+
+// ParseMapping parses a mapping spec like "isbn=ISBN,title=書名,author=2"
+func ParseMapping(spec string) (Mapping, error) {
+	m := Mapping{}
+	if strings.TrimSpace(spec) == "" {
+		return m, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mapping %q: expected field=column", part)
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isMappingField(field) {
+			return nil, fmt.Errorf("unknown mapping field %q (supported: %s)", field, strings.Join(mappingFields, ", "))
+		}
+		m[field] = strings.TrimSpace(column)
+	}
+
+	return m, nil
+}
+
+func isMappingField(field string) bool {
+	for _, f := range mappingFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColumns turns a mapping into column indexes using the header row
+// Fields without an explicit mapping fall back to well-known header names
+func resolveColumns(mapping Mapping, header []string) (map[string]int, error) {
+	normalized := make([]string, len(header))
+	for i, h := range header {
+		normalized[i] = strings.ToLower(strings.TrimSpace(h))
+	}
+
+	columns := make(map[string]int)
+	for _, field := range mappingFields {
+		if column, ok := mapping[field]; ok {
+			if idx, err := strconv.Atoi(column); err == nil {
+				columns[field] = idx
+				continue
+			}
+			idx := indexOf(normalized, strings.ToLower(column))
+			if idx < 0 {
+				return nil, fmt.Errorf("column %q for %s not found in header", column, field)
+			}
+			columns[field] = idx
+			continue
+		}
+
+		for _, name := range defaultHeaders[field] {
+			if idx := indexOf(normalized, name); idx >= 0 {
+				columns[field] = idx
+				break
+			}
+		}
+	}
+
+	if _, ok := columns["isbn"]; !ok {
+		if _, ok := columns["title"]; !ok {
+			return nil, fmt.Errorf("no isbn or title column found; use a column mapping")
+		}
+	}
+
+	return columns, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// readCSVWithHeader reads a CSV source whose first record is a header row
+func readCSVWithHeader(r io.Reader, mapping Mapping) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns, err := resolveColumns(mapping, header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		rows = append(rows, Row{
+			Line:      line,
+			ISBN:      NormalizeISBN(field(record, columns, "isbn")),
+			Title:     field(record, columns, "title"),
+			Author:    field(record, columns, "author"),
+			Publisher: field(record, columns, "publisher"),
+			Tags:      splitTags(field(record, columns, "tags")),
+		})
+	}
+
+	return rows, nil
+}
+
+func field(record []string, columns map[string]int, name string) string {
+	idx, ok := columns[name]
+	if !ok || idx < 0 || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTags splits a tag cell on commas, spaces and Japanese separators
+func splitTags(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '　' || r == '、' || r == '|' || r == ';'
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// newGenericCSVReader reads a CSV file with a header row and optional column mapping
+func newGenericCSVReader(opts Options) Reader {
+	return func(r io.Reader) ([]Row, error) {
+		return readCSVWithHeader(r, opts.Mapping)
+	}
+}
+
+// Booklog export column positions
+// ブクログ's export has no header row and a fixed column layout
+const (
+	booklogISBN      = 2
+	booklogTags      = 7
+	booklogTitle     = 11
+	booklogAuthor    = 12
+	booklogPublisher = 13
+)
+
+// newBooklogReader reads a ブクログ (booklog.jp) CSV export
+func newBooklogReader(opts Options) Reader {
+	return func(r io.Reader) ([]Row, error) {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.LazyQuotes = true
+
+		var rows []Row
+		line := 0
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			line++
+			if err != nil {
+				return nil, fmt.Errorf("failed to read booklog line %d: %w", line, err)
+			}
+			if isBlank(record) {
+				continue
+			}
+			if len(record) <= booklogPublisher {
+				return nil, fmt.Errorf("booklog line %d: expected at least %d columns, got %d", line, booklogPublisher+1, len(record))
+			}
+			rows = append(rows, Row{
+				Line:      line,
+				ISBN:      NormalizeISBN(record[booklogISBN]),
+				Title:     strings.TrimSpace(record[booklogTitle]),
+				Author:    strings.TrimSpace(record[booklogAuthor]),
+				Publisher: strings.TrimSpace(record[booklogPublisher]),
+				Tags:      splitTags(record[booklogTags]),
+			})
+		}
+
+		return rows, nil
+	}
+}
+
+// newBookmeterReader reads a 読書メーター CSV export
+// 読書メーター has no official export; the common exporters write a header row
+// with タイトル, 著者 and ISBN/ASIN columns, which defaultHeaders recognizes
+func newBookmeterReader(opts Options) Reader {
+	return func(r io.Reader) ([]Row, error) {
+		return readCSVWithHeader(r, opts.Mapping)
+	}
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// Row represents a single entry read from an import source
+type Row struct {
+	Line      int // Line or record number in the source, for reporting
+	ISBN      string
+	Title     string
+	Author    string
+	Publisher string
+	Tags      []string
+}
+
+// Reader parses an import source into rows
+type Reader func(r io.Reader) ([]Row, error)
+
+// Options holds settings shared by the format adapters
+type Options struct {
+	Mapping  Mapping // Column mapping for generic CSV/JSON
+	Encoding string  // "utf8", "sjis" or "" for auto-detect
+}
+
+// formats maps format names to adapter constructors
+var formats = map[string]func(opts Options) Reader{
+	"csv":       newGenericCSVReader,
+	"json":      newJSONReader,
+	"booklog":   newBooklogReader,
+	"bookmeter": newBookmeterReader,
+	"calibre":   newCalibreOPFReader,
+}
+
+// Formats returns the supported format names
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadFile reads rows from a file using the named format adapter
+// Calibre libraries may be given as a directory, which is read from its
+// metadata.db or else every metadata.opf below it, or as a metadata.db file
+func ReadFile(path, format string, opts Options) ([]Row, error) {
+	newReader, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format: %s (supported: %s)", format, strings.Join(Formats(), ", "))
+	}
+
+	if format == "calibre" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.IsDir() {
+			return readCalibreLibrary(path)
+		}
+		if strings.HasSuffix(path, ".db") {
+			return readCalibreDB(path)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r, err := decode(f, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReader(opts)(r)
+}
+
+// decode converts the input to UTF-8
+// Shift_JIS is assumed when auto-detecting and the input is not valid UTF-8,
+// which is what ブクログ and older spreadsheet exports produce
+func decode(r io.Reader, encoding string) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	switch strings.ToLower(encoding) {
+	case "", "auto":
+		if !utf8.Valid(data) {
+			return transform.NewReader(bytes.NewReader(data), japanese.ShiftJIS.NewDecoder()), nil
+		}
+	case "sjis", "shift_jis", "cp932":
+		return transform.NewReader(bytes.NewReader(data), japanese.ShiftJIS.NewDecoder()), nil
+	case "utf8", "utf-8":
+	default:
+		return nil, fmt.Errorf("unknown encoding: %s", encoding)
+	}
+
+	// Strip UTF-8 BOM written by Excel
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bytes.NewReader(data), nil
+}
+
+// NormalizeISBN strips hyphens and spaces and converts ISBN-10 to ISBN-13
+// Returns an empty string if the value is not a valid ISBN
+func NormalizeISBN(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= '０' && r <= '９':
+			b.WriteRune(r - '０' + '0')
+		case r == 'X' || r == 'x':
+			b.WriteRune('X')
+		}
+	}
+	digits := b.String()
+
+	switch len(digits) {
+	case 13:
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return ""
+		}
+		if strings.Contains(digits, "X") {
+			return ""
+		}
+		return digits
+	case 10:
+		body := "978" + digits[:9]
+		if strings.Contains(body, "X") {
+			return ""
+		}
+		sum := 0
+		for i, r := range body {
+			d := int(r - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		check := (10 - sum%10) % 10
+		return fmt.Sprintf("%s%d", body, check)
+	}
+
+	return ""
+}
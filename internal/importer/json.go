@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// newJSONReader reads a JSON array of objects or JSON Lines
+// Keys are matched with the same mapping and header names as CSV
+func newJSONReader(opts Options) Reader {
+	return func(r io.Reader) ([]Row, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JSON: %w", err)
+		}
+
+		var records []map[string]interface{}
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &records); err != nil {
+				return nil, fmt.Errorf("failed to parse JSON: %w", err)
+			}
+		} else {
+			dec := json.NewDecoder(bytes.NewReader(trimmed))
+			for dec.More() {
+				var rec map[string]interface{}
+				if err := dec.Decode(&rec); err != nil {
+					return nil, fmt.Errorf("failed to parse JSON record %d: %w", len(records)+1, err)
+				}
+				records = append(records, rec)
+			}
+		}
+
+		rows := make([]Row, 0, len(records))
+		for i, rec := range records {
+			rows = append(rows, Row{
+				Line:      i + 1,
+				ISBN:      NormalizeISBN(jsonField(rec, opts.Mapping, "isbn")),
+				Title:     jsonField(rec, opts.Mapping, "title"),
+				Author:    jsonField(rec, opts.Mapping, "author"),
+				Publisher: jsonField(rec, opts.Mapping, "publisher"),
+				Tags:      splitTags(jsonField(rec, opts.Mapping, "tags")),
+			})
+		}
+
+		return rows, nil
+	}
+}
+
+// jsonField looks up a row field in a JSON object, case-insensitively
+func jsonField(rec map[string]interface{}, mapping Mapping, name string) string {
+	keys := defaultHeaders[name]
+	if column, ok := mapping[name]; ok {
+		keys = []string{strings.ToLower(column)}
+	}
+
+	for k, v := range rec {
+		if indexOf(keys, strings.ToLower(k)) < 0 {
+			continue
+		}
+		switch val := v.(type) {
+		case string:
+			return strings.TrimSpace(val)
+		case float64:
+			return fmt.Sprintf("%.0f", val)
+		case []interface{}:
+			parts := make([]string, 0, len(val))
+			for _, p := range val {
+				parts = append(parts, fmt.Sprint(p))
+			}
+			return strings.Join(parts, ",")
+		}
+	}
+	return ""
+}
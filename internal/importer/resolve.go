@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/manga"
+)
+
+// Status is the outcome of resolving an import row
+type Status string
+
+const (
+	StatusMatched   Status = "matched"   // Resolved to exactly one book
+	StatusAmbiguous Status = "ambiguous" // Several candidates; needs manual choice
+	StatusFailed    Status = "failed"    // No candidate or lookup error
+)
+
+// Result is the resolution of a single row
+type Result struct {
+	Row        Row
+	Status     Status
+	Manga      *manga.Manga   // Set when matched
+	Candidates []api.BookInfo // Set when ambiguous
+	Existing   bool           // Already registered in the collection
+	Reason     string
+}
+
+// Report collects the results of an import run
+type Report struct {
+	Results []Result
+}
+
+// Count returns the number of results with the given status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Resolver resolves import rows through a book provider
+type Resolver struct {
+	Provider api.Provider
+	Interval time.Duration // Minimum delay between provider requests
+	last     time.Time
+}
+
+// NewResolver creates a resolver
+// Rakuten allows roughly one request per second per application ID
+func NewResolver(provider api.Provider) *Resolver {
+	return &Resolver{
+		Provider: provider,
+		Interval: time.Second,
+	}
+}
+
+// Resolve resolves all rows and checks them against the existing collection
+func (r *Resolver) Resolve(rows []Row, mgr *manga.Manager) *Report {
+	report := &Report{Results: make([]Result, 0, len(rows))}
+	for _, row := range rows {
+		res := r.resolveRow(row)
+		if res.Manga != nil {
+			if _, err := mgr.GetByISBN(res.Manga.ISBN); err == nil {
+				res.Existing = true
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+func (r *Resolver) resolveRow(row Row) Result {
+	res := Result{Row: row}
+
+	if row.ISBN == "" && row.Title == "" {
+		res.Status = StatusFailed
+		res.Reason = "no ISBN or title"
+		return res
+	}
+
+	if row.ISBN != "" {
+		book, err := r.searchByISBN(row.ISBN)
+		if err == nil && book.Isbn == row.ISBN {
+			return matched(res, *book)
+		}
+		// Rakuten sometimes returns a different book for isbnjan,
+		// so fall back to a title search and look for the ISBN there
+		if row.Title == "" {
+			res.Status = StatusFailed
+			if err != nil {
+				res.Reason = fmt.Sprintf("ISBN lookup failed: %v", err)
+			} else {
+				res.Reason = fmt.Sprintf("ISBN lookup returned %s", book.Isbn)
+			}
+			return res
+		}
+	}
+
+	books, err := r.searchByTitle(row.Title)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Reason = fmt.Sprintf("title search failed: %v", err)
+		return res
+	}
+
+	if row.ISBN != "" {
+		for _, book := range books {
+			if book.Isbn == row.ISBN {
+				return matched(res, book)
+			}
+		}
+	}
+
+	candidates := filterCandidates(books, row)
+	switch len(candidates) {
+	case 0:
+		res.Status = StatusFailed
+		res.Reason = "no matching title"
+	case 1:
+		return matched(res, candidates[0])
+	default:
+		res.Status = StatusAmbiguous
+		res.Candidates = candidates
+		res.Reason = fmt.Sprintf("%d candidates", len(candidates))
+	}
+	return res
+}
+
+func matched(res Result, book api.BookInfo) Result {
+	m := manga.FromBookInfo(book)
+	m.Tags = res.Row.Tags
+	res.Status = StatusMatched
+	res.Manga = &m
+	return res
+}
+
+// filterCandidates narrows title search results using the row's title and author
+// An exact title match wins over partial matches
+func filterCandidates(books []api.BookInfo, row Row) []api.BookInfo {
+	author := compact(row.Author)
+	title := compact(row.Title)
+
+	var byAuthor []api.BookInfo
+	for _, book := range books {
+		if author != "" && !strings.Contains(compact(book.Author), author) {
+			continue
+		}
+		byAuthor = append(byAuthor, book)
+	}
+
+	for _, book := range byAuthor {
+		if compact(book.Title) == title {
+			return []api.BookInfo{book}
+		}
+	}
+
+	return byAuthor
+}
+
+// compact removes all whitespace so that "ダンダダン 1" and "ダンダダン　1" compare equal
+func compact(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+func (r *Resolver) searchByISBN(isbn string) (*api.BookInfo, error) {
+	r.wait()
+	return r.Provider.SearchByISBN(isbn)
+}
+
+func (r *Resolver) searchByTitle(title string) ([]api.BookInfo, error) {
+	r.wait()
+	return r.Provider.SearchByTitle(title)
+}
+
+func (r *Resolver) wait() {
+	if !r.last.IsZero() {
+		if d := r.Interval - time.Since(r.last); d > 0 {
+			time.Sleep(d)
+		}
+	}
+	r.last = time.Now()
+}
+
+// Apply registers all matched, not yet registered results in the collection
+// Returns the number of manga added
+func Apply(report *Report, mgr *manga.Manager) (int, error) {
+	added := 0
+	for _, res := range report.Results {
+		if res.Status != StatusMatched || res.Existing {
+			continue
+		}
+		if err := mgr.Add(*res.Manga); err != nil {
+			return added, fmt.Errorf("failed to add %s: %w", res.Manga.ISBN, err)
+		}
+		added++
+	}
+	return added, nil
+}
@@ -3,6 +3,7 @@ package manga
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/kench/komikan-go/internal/api"
 )
@@ -17,41 +18,94 @@ type NewReleaseCheckResult struct {
 	ISBN          string
 	URL           string
 	SalesDate     string
+	CoverURL      string
+
+	// Announce is set when the public timeline should hear about the
+	// release: the series is in the shared collection and not muted
+	Announce bool
+	// Subscribers are the public keys to send the release to privately
+	Subscribers []string
+	// Members are the public keys of member libraries missing the release
+	Members []string
 }
 
-// CheckNewReleases checks for new releases for registered manga
-func (m *Manager) CheckNewReleases(rakutenAPIKey string) ([]NewReleaseCheckResult, error) {
-	// Get all registered manga
-	allManga, err := m.List()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list manga: %w", err)
+// seriesWatch is a library holding a series
+type seriesWatch struct {
+	lib    *Manager
+	latest int // Newest volume in the library
+}
+
+// CheckNewReleases checks for new releases of the series in the shared
+// library, in the libraries of the given members and of subscribers.
+// Each series is looked up once, however many libraries hold it.
+// Subscribers and members are only listed for volumes they have not been
+// sent; call MarkReleaseNotified (through ForUser for members) once they
+// have been.
+func (m *Manager) CheckNewReleases(rakutenAPIKey string, members []string) ([]NewReleaseCheckResult, error) {
+	libraries := []*Manager{m}
+	for _, pk := range members {
+		libraries = append(libraries, m.ForUser(pk))
+	}
+
+	// Group by series title across libraries
+	watches := make(map[string][]seriesWatch)
+	for _, lib := range libraries {
+		allManga, err := lib.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list manga: %w", err)
+		}
+
+		latest := make(map[string]int)
+		for _, mg := range allManga {
+			if mg.Series == "" {
+				continue // Skip non-series manga
+			}
+			latest[mg.Series] = max(latest[mg.Series], mg.Volume)
+		}
+
+		for series, vol := range latest {
+			if vol == 0 {
+				continue // Skip if no volume info
+			}
+			if muted, err := lib.IsMuted(series); err != nil {
+				return nil, err
+			} else if muted {
+				continue // Announcements turned off for this series
+			}
+			watches[series] = append(watches[series], seriesWatch{lib: lib, latest: vol})
+		}
 	}
 
-	// Group by series title
-	seriesMap := make(map[string][]Manga)
-	for _, mg := range allManga {
-		if mg.Series == "" {
-			continue // Skip non-series manga
+	// Series only followed by subscribers have no library volumes
+	subscribed, err := m.SubscribedSeries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribed series: %w", err)
+	}
+	for _, s := range subscribed {
+		if _, ok := watches[s]; !ok {
+			watches[s] = nil
 		}
-		seriesMap[mg.Series] = append(seriesMap[mg.Series], mg)
 	}
 
 	client := api.NewRakutenClient(rakutenAPIKey)
 	var newReleases []NewReleaseCheckResult
 
 	// Check each series for new releases
-	for seriesTitle, manga := range seriesMap {
-		// Get current latest volume from local database
-		currentLatest := 0
-		for _, mg := range manga {
-			if mg.Volume > currentLatest {
-				currentLatest = mg.Volume
-			}
+	searched := 0
+	for seriesTitle, libs := range watches {
+		subscribers, err := m.SubscribersOf(seriesTitle)
+		if err != nil {
+			return nil, err
+		}
+		if len(libs) == 0 && len(subscribers) == 0 {
+			continue
 		}
 
-		if currentLatest == 0 {
-			continue // Skip if no volume info
+		if searched > 0 {
+			// Respect the Rakuten API rate limit
+			time.Sleep(time.Second)
 		}
+		searched++
 
 		// Search Rakuten for latest volume
 		books, err := client.SearchByTitleSorted(seriesTitle, "-releaseDate", 30)
@@ -71,18 +125,56 @@ func (m *Manager) CheckNewReleases(rakutenAPIKey string) ([]NewReleaseCheckResul
 			}
 		}
 
-		// Check if new volume is available
-		if latestBook != nil && latestVolume > currentLatest {
-			result := NewReleaseCheckResult{
-				SeriesTitle:    seriesTitle,
-				LatestVolume:   latestVolume,
-				PreviousVolume: currentLatest,
-				NewVolume:      latestVolume,
-				Author:         latestBook.Author,
-				ISBN:           latestBook.Isbn,
-				URL:            latestBook.ItemURL,
-				SalesDate:      latestBook.SalesDate,
+		if latestBook == nil {
+			continue
+		}
+
+		result := NewReleaseCheckResult{
+			SeriesTitle:  seriesTitle,
+			LatestVolume: latestVolume,
+			NewVolume:    latestVolume,
+			Author:       latestBook.Author,
+			ISBN:         latestBook.Isbn,
+			URL:          latestBook.ItemURL,
+			SalesDate:    latestBook.SalesDate,
+			CoverURL:     latestBook.LargeImage,
+		}
+
+		for _, w := range libs {
+			if w.lib.User() == "" {
+				result.PreviousVolume = w.latest
+				result.Announce = latestVolume > w.latest
+				continue
+			}
+			// Members are told about each volume once
+			pending, err := w.lib.trackRelease(seriesTitle, latestVolume, w.latest)
+			if err != nil {
+				return nil, err
 			}
+			if pending {
+				result.Members = append(result.Members, w.lib.User())
+			}
+		}
+
+		// New subscribers only hear about volumes released after they
+		// subscribed, so keep the state current while there are none
+		seen := 0
+		if len(subscribers) == 0 {
+			seen = latestVolume
+		} else if _, found, err := m.getReleaseState(seriesTitle); err != nil {
+			return nil, err
+		} else if !found {
+			seen = latestVolume
+		}
+		pending, err := m.trackRelease(seriesTitle, latestVolume, seen)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			result.Subscribers = subscribers
+		}
+
+		if result.Announce || len(result.Members) > 0 || len(result.Subscribers) > 0 {
 			newReleases = append(newReleases, result)
 		}
 	}
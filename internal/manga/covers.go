@@ -0,0 +1,81 @@
+package manga
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/cover"
+)
+
+// ErrNoCover is returned for manga without a cover URL
+var ErrNoCover = errors.New("no cover URL")
+
+// CoverURL returns the largest cover URL known for a manga
+func (mg Manga) CoverURL() string {
+	for _, u := range []string{mg.CoverLarge, mg.CoverMedium, mg.CoverSmall} {
+		if u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// CacheCover makes sure the cover of a manga is in the cache
+// A cover already cached is returned without downloading it again
+func (m *Manager) CacheCover(cache *cover.Cache, isbn string) (cover.Entry, error) {
+	mg, err := m.GetByISBN(isbn)
+	if err != nil {
+		return cover.Entry{}, err
+	}
+
+	if mg.CoverHash != "" {
+		entry, err := cache.Get(mg.CoverHash)
+		if err == nil {
+			return entry, nil
+		}
+		if !errors.Is(err, cover.ErrNotCached) {
+			return cover.Entry{}, err
+		}
+	}
+
+	src := mg.CoverURL()
+	if src == "" {
+		return cover.Entry{}, ErrNoCover
+	}
+
+	entry, err := cache.Download(cover.OriginalURL(src))
+	if err != nil {
+		return cover.Entry{}, err
+	}
+
+	if entry.Hash != mg.CoverHash {
+		mg.CoverHash = entry.Hash
+		if err := m.Add(*mg); err != nil {
+			return cover.Entry{}, fmt.Errorf("failed to save cover hash: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// RefreshCoverURLs looks up a manga and stores its current cover URLs
+func (m *Manager) RefreshCoverURLs(provider api.Provider, isbn string) (*Manga, error) {
+	mg, err := m.GetByISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := provider.SearchByISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
+	// ISBN search sometimes returns a different book
+	if book.Isbn != isbn {
+		return nil, fmt.Errorf("lookup of %s returned %s", isbn, book.Isbn)
+	}
+
+	mg.CoverSmall = book.SmallImage
+	mg.CoverMedium = book.MediumImage
+	mg.CoverLarge = book.LargeImage
+	return mg, m.Add(*mg)
+}
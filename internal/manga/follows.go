@@ -0,0 +1,217 @@
+package manga
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// maxSeenReleases bounds the announced ISBNs remembered per author
+const maxSeenReleases = 100
+
+// FollowedAuthor is an author whose new books are announced
+type FollowedAuthor struct {
+	Author  string    `json:"author"`
+	Since   string    `json:"since"` // Only books released on or after this date are announced
+	Seen    []string  `json:"seen,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// MutedSeries is a series whose new releases are not announced
+type MutedSeries struct {
+	Series  string    `json:"series"`
+	MutedAt time.Time `json:"muted_at"`
+}
+
+// followRecords stores followed authors keyed by whitespace-free name
+var followRecords = db.NewCollection[FollowedAuthor]("follow:author:", func(f FollowedAuthor) string {
+	return compactKey(f.Author)
+})
+
+// muteRecords stores muted series keyed by series name
+var muteRecords = db.NewCollection[MutedSeries]("mute:series:", func(s MutedSeries) string {
+	return s.Series
+})
+
+// Follow starts announcing new books by an author
+// Books already released are not announced
+func (m *Manager) Follow(author string) error {
+	if compactKey(author) == "" {
+		return fmt.Errorf("author is required")
+	}
+	return m.db.Update(func(txn *db.Txn) error {
+		if _, err := followRecords.Get(txn, compactKey(author)); err == nil {
+			return nil
+		} else if !db.IsNotFound(err) {
+			return err
+		}
+		return followRecords.Put(txn, FollowedAuthor{
+			Author:  author,
+			Since:   time.Now().Format("2006-01-02"),
+			AddedAt: time.Now(),
+		})
+	})
+}
+
+// Unfollow stops announcing new books by an author
+func (m *Manager) Unfollow(author string) error {
+	return m.db.Update(func(txn *db.Txn) error {
+		if _, err := followRecords.Get(txn, compactKey(author)); db.IsNotFound(err) {
+			return fmt.Errorf("not following %s", author)
+		}
+		return followRecords.Delete(txn, compactKey(author))
+	})
+}
+
+// ListFollows returns the followed authors
+func (m *Manager) ListFollows() ([]FollowedAuthor, error) {
+	var follows []FollowedAuthor
+	err := m.db.View(func(txn *db.Txn) error {
+		page, err := followRecords.Scan(txn, db.Query[FollowedAuthor]{})
+		follows = page.Items
+		return err
+	})
+	return follows, err
+}
+
+// Mute stops announcing new releases of a series
+func (m *Manager) Mute(series string) error {
+	if series == "" {
+		return fmt.Errorf("series is required")
+	}
+	return m.db.Update(func(txn *db.Txn) error {
+		return muteRecords.Put(txn, MutedSeries{Series: series, MutedAt: time.Now()})
+	})
+}
+
+// Unmute resumes announcing new releases of a series
+func (m *Manager) Unmute(series string) error {
+	return m.db.Update(func(txn *db.Txn) error {
+		if _, err := muteRecords.Get(txn, series); db.IsNotFound(err) {
+			return fmt.Errorf("%s is not muted", series)
+		}
+		return muteRecords.Delete(txn, series)
+	})
+}
+
+// IsMuted reports whether a series is muted
+func (m *Manager) IsMuted(series string) (bool, error) {
+	var muted bool
+	err := m.db.View(func(txn *db.Txn) error {
+		_, err := muteRecords.Get(txn, series)
+		if db.IsNotFound(err) {
+			return nil
+		}
+		muted = err == nil
+		return err
+	})
+	return muted, err
+}
+
+// AuthorRelease is a new book by a followed author
+type AuthorRelease struct {
+	Author string
+	Book   api.BookInfo
+	Member string // Public key of the member library, empty for the shared one
+}
+
+// CheckAuthorReleases looks for new books by authors followed in the
+// shared library and the given member libraries that are not in the
+// following library. Each author is searched once and each book is only
+// reported once per library.
+func (m *Manager) CheckAuthorReleases(rakutenAPIKey string, members []string) ([]AuthorRelease, error) {
+	libraries := []*Manager{m}
+	for _, pk := range members {
+		libraries = append(libraries, m.ForUser(pk))
+	}
+
+	client := api.NewRakutenClient(rakutenAPIKey)
+	searched := make(map[string][]api.BookInfo)
+	var releases []AuthorRelease
+
+	for _, lib := range libraries {
+		follows, err := lib.ListFollows()
+		if err != nil {
+			return releases, fmt.Errorf("failed to list followed authors: %w", err)
+		}
+
+		for _, f := range follows {
+			books, ok := searched[compactKey(f.Author)]
+			if !ok {
+				if len(searched) > 0 {
+					// Respect the Rakuten API rate limit
+					time.Sleep(time.Second)
+				}
+				books, err = client.SearchByAuthor(f.Author, 30)
+				if err != nil {
+					log.Printf("Failed to search for %s: %v", f.Author, err)
+					continue
+				}
+				searched[compactKey(f.Author)] = books
+			}
+
+			found, err := lib.newAuthorReleases(f, books)
+			if err != nil {
+				return releases, err
+			}
+			releases = append(releases, found...)
+		}
+	}
+
+	return releases, nil
+}
+
+// newAuthorReleases picks the books by a followed author that have not
+// been reported and are not in the library, and marks them as seen
+func (m *Manager) newAuthorReleases(f FollowedAuthor, books []api.BookInfo) ([]AuthorRelease, error) {
+	var found []AuthorRelease
+	for _, book := range books {
+		if book.Isbn == "" || slices.Contains(f.Seen, book.Isbn) {
+			continue
+		}
+		if NormalizeDate(book.SalesDate) < f.Since {
+			continue
+		}
+		if _, err := m.GetByISBN(book.Isbn); err == nil {
+			continue // Already in the collection
+		}
+		if info := ExtractVolumeInfo(book.Title); info.HasVolume {
+			if muted, err := m.IsMuted(info.Title); err == nil && muted {
+				continue
+			}
+		}
+		found = append(found, AuthorRelease{Author: f.Author, Book: book, Member: m.user})
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	err := m.db.Update(func(txn *db.Txn) error {
+		for _, r := range found {
+			f.Seen = append(f.Seen, r.Book.Isbn)
+		}
+		if len(f.Seen) > maxSeenReleases {
+			f.Seen = f.Seen[len(f.Seen)-maxSeenReleases:]
+		}
+		return followRecords.Put(txn, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// ListMuted returns the muted series
+func (m *Manager) ListMuted() ([]MutedSeries, error) {
+	var muted []MutedSeries
+	err := m.db.View(func(txn *db.Txn) error {
+		page, err := muteRecords.Scan(txn, db.Query[MutedSeries]{})
+		muted = page.Items
+		return err
+	})
+	return muted, err
+}
@@ -0,0 +1,53 @@
+package manga
+
+import (
+	"sort"
+)
+
+// SeriesGap lists the volumes missing from an owned series
+type SeriesGap struct {
+	Series  string
+	Latest  int   // Highest owned volume
+	Missing []int // Volumes below Latest that are not owned
+}
+
+// Gaps returns the series with missing volumes between 1 and the highest
+// owned volume. Wishlist and preorder entries do not count as owned.
+func (m *Manager) Gaps() ([]SeriesGap, error) {
+	owned, err := m.FindByStatus(StatusOwned)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := map[string]map[int]bool{}
+	for _, mg := range owned {
+		if mg.Series == "" || mg.Volume <= 0 {
+			continue
+		}
+		if volumes[mg.Series] == nil {
+			volumes[mg.Series] = map[int]bool{}
+		}
+		volumes[mg.Series][mg.Volume] = true
+	}
+
+	var gaps []SeriesGap
+	for series, vols := range volumes {
+		gap := SeriesGap{Series: series}
+		for v := range vols {
+			gap.Latest = max(gap.Latest, v)
+		}
+		for v := 1; v < gap.Latest; v++ {
+			if !vols[v] {
+				gap.Missing = append(gap.Missing, v)
+			}
+		}
+		if len(gap.Missing) > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+
+	sort.Slice(gaps, func(i, j int) bool {
+		return gaps[i].Series < gaps[j].Series
+	})
+	return gaps, nil
+}
@@ -0,0 +1,239 @@
+package manga
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// mangaRecords stores manga entries keyed by ISBN
+var mangaRecords = db.NewCollection[Manga]("manga:isbn:", func(m Manga) string {
+	return m.ISBN
+})
+
+// Secondary indexes over mangaRecords, maintained on every write
+var (
+	bySeries = mangaRecords.AddIndex("series", func(m Manga) []string {
+		return []string{m.Series}
+	})
+	byAuthor = mangaRecords.AddIndex("author", func(m Manga) []string {
+		return authorKeys(m.Author)
+	})
+	byPublisher = mangaRecords.AddIndex("publisher", func(m Manga) []string {
+		return []string{compactKey(m.Publisher)}
+	})
+	byTag = mangaRecords.AddIndex("tag", func(m Manga) []string {
+		return m.Tags
+	})
+	byPublishDate = mangaRecords.AddIndex("publish_date", func(m Manga) []string {
+		return []string{NormalizeDate(m.PublishDate)}
+	})
+	byStatus = mangaRecords.AddIndex("status", func(m Manga) []string {
+		return []string{m.Status}
+	})
+)
+
+// compactKey removes whitespace so "藤本 タツキ" and "藤本タツキ" match
+func compactKey(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// authorKeys splits Rakuten's "/"-separated author field into index keys
+func authorKeys(author string) []string {
+	var keys []string
+	for _, a := range strings.Split(author, "/") {
+		if k := compactKey(a); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// datePattern matches Rakuten sales dates like "2024年05月02日頃" or "2024年05月"
+var datePattern = regexp.MustCompile(`(\d{4})\D*(\d{1,2})?\D*(\d{1,2})?`)
+
+// NormalizeDate converts a publish date to a sortable YYYY-MM-DD form
+// Missing month or day parts are left out, e.g. "2024-05"
+func NormalizeDate(s string) string {
+	m := datePattern.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	date := m[1]
+	if month, err := strconv.Atoi(m[2]); err == nil {
+		date += fmt.Sprintf("-%02d", month)
+		if day, err := strconv.Atoi(m[3]); err == nil {
+			date += fmt.Sprintf("-%02d", day)
+		}
+	}
+	return date
+}
+
+// findBy returns the records with exactly the given index value
+func (m *Manager) findBy(idx *db.Index[Manga], value string) ([]Manga, error) {
+	var list []Manga
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		list, err = idx.Find(txn, value)
+		return err
+	})
+	return list, err
+}
+
+// FindByAuthor returns manga by an author, ignoring whitespace differences
+func (m *Manager) FindByAuthor(author string) ([]Manga, error) {
+	return m.findBy(byAuthor, compactKey(author))
+}
+
+// FindByPublisher returns manga from a publisher
+func (m *Manager) FindByPublisher(publisher string) ([]Manga, error) {
+	return m.findBy(byPublisher, compactKey(publisher))
+}
+
+// FindByTag returns manga with a tag
+func (m *Manager) FindByTag(tag string) ([]Manga, error) {
+	return m.findBy(byTag, tag)
+}
+
+// FindByStatus returns manga with a status (owned, wishlist, preorder)
+func (m *Manager) FindByStatus(status string) ([]Manga, error) {
+	return m.findBy(byStatus, status)
+}
+
+// FindPublishedSince returns manga published on or after a date, oldest first
+// since accepts the same formats as NormalizeDate
+func (m *Manager) FindPublishedSince(since string) ([]Manga, error) {
+	from := NormalizeDate(since)
+	if from == "" {
+		return nil, fmt.Errorf("invalid date: %s", since)
+	}
+
+	var list []Manga
+	err := m.db.View(func(txn *db.Txn) error {
+		ids, err := byPublishDate.Range(txn, from, "")
+		if err != nil {
+			return err
+		}
+		list, err = mangaRecords.GetMany(txn, ids)
+		return err
+	})
+	return list, err
+}
+
+// ListOptions filters and orders Manager.Filter results
+// Empty fields are not filtered on
+type ListOptions struct {
+	Author    string
+	Publisher string
+	Tag       string
+	Series    string
+	Status    string
+	Since     string // Published on or after this date
+	Sort      string // title, date, series, author or isbn (default)
+	Limit     int
+}
+
+// SortKeys lists the values accepted by ListOptions.Sort
+var SortKeys = []string{"isbn", "title", "date", "series", "author"}
+
+// Filter returns manga matching all given options
+// The most selective index narrows the candidates; remaining options are
+// checked on those records only, so no full scan is needed when any
+// indexed filter is set
+func (m *Manager) Filter(opts ListOptions) ([]Manga, error) {
+	less, err := sortFunc(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Manga
+	switch {
+	case opts.Series != "":
+		list, err = m.GetBySeries(opts.Series)
+	case opts.Author != "":
+		list, err = m.FindByAuthor(opts.Author)
+	case opts.Tag != "":
+		list, err = m.FindByTag(opts.Tag)
+	case opts.Publisher != "":
+		list, err = m.FindByPublisher(opts.Publisher)
+	case opts.Since != "":
+		list, err = m.FindPublishedSince(opts.Since)
+	case opts.Status != "":
+		list, err = m.FindByStatus(opts.Status)
+	default:
+		list, err = m.List()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	since := NormalizeDate(opts.Since)
+	filtered := list[:0]
+	for _, mg := range list {
+		if opts.Series != "" && mg.Series != opts.Series {
+			continue
+		}
+		if opts.Author != "" && !containsString(authorKeys(mg.Author), compactKey(opts.Author)) {
+			continue
+		}
+		if opts.Tag != "" && !containsString(mg.Tags, opts.Tag) {
+			continue
+		}
+		if opts.Publisher != "" && compactKey(mg.Publisher) != compactKey(opts.Publisher) {
+			continue
+		}
+		if since != "" && NormalizeDate(mg.PublishDate) < since {
+			continue
+		}
+		if opts.Status != "" && mg.Status != opts.Status {
+			continue
+		}
+		filtered = append(filtered, mg)
+	}
+
+	if less != nil {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return less(filtered[i], filtered[j])
+		})
+	}
+	if opts.Limit > 0 && opts.Limit < len(filtered) {
+		filtered = filtered[:opts.Limit]
+	}
+	return filtered, nil
+}
+
+func sortFunc(key string) (func(a, b Manga) bool, error) {
+	switch key {
+	case "", "isbn":
+		return func(a, b Manga) bool { return a.ISBN < b.ISBN }, nil
+	case "title":
+		return func(a, b Manga) bool { return a.Title < b.Title }, nil
+	case "date":
+		return func(a, b Manga) bool {
+			return NormalizeDate(a.PublishDate) < NormalizeDate(b.PublishDate)
+		}, nil
+	case "series":
+		return func(a, b Manga) bool {
+			if a.Series != b.Series {
+				return a.Series < b.Series
+			}
+			return a.Volume < b.Volume
+		}, nil
+	case "author":
+		return func(a, b Manga) bool { return compactKey(a.Author) < compactKey(b.Author) }, nil
+	}
+	return nil, fmt.Errorf("unknown sort key %q (supported: %s)", key, strings.Join(SortKeys, ", "))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
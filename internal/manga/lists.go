@@ -0,0 +1,108 @@
+package manga
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// Book list names
+const (
+	ListLibrary  = "library"  // Owned volumes
+	ListWishlist = "wishlist" // Wishlist and preorder volumes
+	ListSeries   = "series"   // Series watched for new releases
+)
+
+// BookList is a shareable view of part of a library
+type BookList struct {
+	Name  string // One of the List* names
+	Title string
+	Items []BookListItem
+}
+
+// BookListItem is a book in a list
+// For the series list it is the newest owned volume of the series.
+type BookListItem struct {
+	ISBN   string
+	Title  string
+	Series string
+	URL    string
+}
+
+// BookLists returns the library, wishlist and series lists
+func (m *Manager) BookLists() ([]BookList, error) {
+	all, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	muted, err := m.ListMuted()
+	if err != nil {
+		return nil, err
+	}
+
+	// Series and volume order, so lists read like a shelf
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Series != b.Series {
+			return a.Series < b.Series
+		}
+		if a.Volume != b.Volume {
+			return a.Volume < b.Volume
+		}
+		return a.ISBN < b.ISBN
+	})
+
+	library := BookList{Name: ListLibrary, Title: "蔵書"}
+	wishlist := BookList{Name: ListWishlist, Title: "ほしい物"}
+	series := BookList{Name: ListSeries, Title: "新刊チェック中のシリーズ"}
+	newest := make(map[string]int) // Series to index in series.Items
+
+	for _, mg := range all {
+		if mg.ISBN == "" {
+			continue
+		}
+		item := BookListItem{ISBN: mg.ISBN, Title: mg.Title, Series: mg.Series, URL: mg.URL}
+		switch mg.Status {
+		case StatusWishlist, StatusPreorder:
+			wishlist.Items = append(wishlist.Items, item)
+			continue
+		}
+		library.Items = append(library.Items, item)
+
+		if mg.Series == "" || slices.ContainsFunc(muted, func(s MutedSeries) bool { return s.Series == mg.Series }) {
+			continue
+		}
+		// Volumes are sorted, so later ones replace earlier ones
+		if i, ok := newest[mg.Series]; ok {
+			series.Items[i] = item
+		} else {
+			newest[mg.Series] = len(series.Items)
+			series.Items = append(series.Items, item)
+		}
+	}
+
+	return []BookList{library, wishlist, series}, nil
+}
+
+// ChangedLibraries returns the libraries whose book lists are affected
+// by writes to the given storage keys (see db.DB.OnChange), as owner
+// public keys with "" for the shared library
+func ChangedLibraries(keys []string) []string {
+	var users []string
+	for _, key := range keys {
+		ns, rest := db.SplitNamespace(key)
+		if !strings.HasPrefix(rest, mangaRecords.Key("")) && !strings.HasPrefix(rest, muteRecords.Key("")) {
+			continue
+		}
+		user, ok := strings.CutPrefix(ns, userNamespace)
+		if !ok && ns != "" {
+			continue
+		}
+		if !slices.Contains(users, user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
@@ -0,0 +1,208 @@
+package manga
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// Loan directions
+const (
+	LoanBorrowed = "borrowed" // Borrowed from a library, rental shop or friend
+	LoanLent     = "lent"     // Lent to a friend
+)
+
+// Loan records a volume borrowed or lent out with a due date
+type Loan struct {
+	ID           string    `json:"id"`
+	Direction    string    `json:"direction"`
+	ISBN         string    `json:"isbn,omitempty"`
+	Title        string    `json:"title"`
+	Counterparty string    `json:"counterparty"` // Lender or borrower
+	Started      string    `json:"started"`      // YYYY-MM-DD
+	Due          string    `json:"due"`          // YYYY-MM-DD
+	Returned     string    `json:"returned,omitempty"`
+	Notes        string    `json:"notes,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Reminders already sent, so each is sent once
+	RemindedBefore bool `json:"reminded_before,omitempty"`
+	RemindedDue    bool `json:"reminded_due,omitempty"`
+}
+
+// Outstanding reports whether the loan has not been returned
+func (l Loan) Outstanding() bool {
+	return l.Returned == ""
+}
+
+// Overdue reports whether the loan is outstanding past its due date
+func (l Loan) Overdue(today string) bool {
+	return l.Outstanding() && l.Due < today
+}
+
+// loanRecords stores loans keyed by ID
+var loanRecords = db.NewCollection[Loan]("loan:id:", func(l Loan) string {
+	return l.ID
+})
+
+// byLoanDue indexes outstanding loans by due date
+var byLoanDue = loanRecords.AddIndex("due", func(l Loan) []string {
+	if !l.Outstanding() {
+		return nil
+	}
+	return []string{l.Due}
+})
+
+// AddLoan records a new loan
+// Title is filled in from the collection when only an ISBN is given
+func (m *Manager) AddLoan(loan Loan) (Loan, error) {
+	if loan.Direction != LoanBorrowed && loan.Direction != LoanLent {
+		return Loan{}, fmt.Errorf("invalid loan direction %q", loan.Direction)
+	}
+	if loan.Due == "" {
+		return Loan{}, fmt.Errorf("a due date (YYYY-MM-DD) is required")
+	}
+	var err error
+	if loan.Due, err = readingDate(loan.Due); err != nil {
+		return Loan{}, err
+	}
+	if loan.Started, err = readingDate(loan.Started); err != nil {
+		return Loan{}, err
+	}
+
+	if loan.Title == "" && loan.ISBN != "" {
+		if mg, err := m.GetByISBN(loan.ISBN); err == nil {
+			loan.Title = mg.Title
+		}
+	}
+	if loan.Title == "" {
+		return Loan{}, fmt.Errorf("a title or registered ISBN is required")
+	}
+
+	loan.CreatedAt = time.Now()
+	loan.ID = strconv.FormatInt(loan.CreatedAt.UnixNano(), 36)
+
+	err = m.db.Update(func(txn *db.Txn) error {
+		return loanRecords.Put(txn, loan)
+	})
+	return loan, err
+}
+
+// ReturnLoan marks a loan as returned on date (today if empty)
+func (m *Manager) ReturnLoan(id, date string) (Loan, error) {
+	date, err := readingDate(date)
+	if err != nil {
+		return Loan{}, err
+	}
+	return m.updateLoan(id, func(l *Loan) error {
+		if !l.Outstanding() {
+			return fmt.Errorf("loan %s was already returned on %s", id, l.Returned)
+		}
+		l.Returned = date
+		return nil
+	})
+}
+
+func (m *Manager) updateLoan(id string, fn func(l *Loan) error) (Loan, error) {
+	var loan Loan
+	err := m.db.Update(func(txn *db.Txn) error {
+		var err error
+		loan, err = loanRecords.Get(txn, id)
+		if db.IsNotFound(err) {
+			return fmt.Errorf("loan %s not found", id)
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(&loan); err != nil {
+			return err
+		}
+		return loanRecords.Put(txn, loan)
+	})
+	return loan, err
+}
+
+// ListLoans returns loans ordered by due date
+// Only outstanding loans are returned unless all is set
+func (m *Manager) ListLoans(all bool) ([]Loan, error) {
+	var loans []Loan
+	err := m.db.View(func(txn *db.Txn) error {
+		if !all {
+			ids, err := byLoanDue.Range(txn, "", "")
+			if err != nil {
+				return err
+			}
+			loans, err = loanRecords.GetMany(txn, ids)
+			return err
+		}
+
+		page, err := loanRecords.Scan(txn, db.Query[Loan]{
+			Less: func(a, b Loan) bool { return a.Due < b.Due },
+		})
+		loans = page.Items
+		return err
+	})
+	return loans, err
+}
+
+// Reminder kinds
+const (
+	ReminderBefore = "before" // Due date is approaching
+	ReminderDue    = "due"    // Due today or overdue
+)
+
+// LoanReminder is a reminder that should be sent for a loan
+type LoanReminder struct {
+	Loan Loan
+	Kind string
+}
+
+// PendingLoanReminders returns reminders not yet sent for outstanding loans
+// due within daysBefore days of today, or already due
+func (m *Manager) PendingLoanReminders(today time.Time, daysBefore int) ([]LoanReminder, error) {
+	todayStr := today.Format("2006-01-02")
+	// Index range end is exclusive, so look one day further
+	limit := today.AddDate(0, 0, daysBefore+1).Format("2006-01-02")
+
+	var reminders []LoanReminder
+	err := m.db.View(func(txn *db.Txn) error {
+		ids, err := byLoanDue.Range(txn, "", limit)
+		if err != nil {
+			return err
+		}
+		loans, err := loanRecords.GetMany(txn, ids)
+		if err != nil {
+			return err
+		}
+		for _, l := range loans {
+			switch {
+			case l.Due <= todayStr && !l.RemindedDue:
+				reminders = append(reminders, LoanReminder{Loan: l, Kind: ReminderDue})
+			case l.Due > todayStr && !l.RemindedBefore:
+				reminders = append(reminders, LoanReminder{Loan: l, Kind: ReminderBefore})
+			}
+		}
+		return nil
+	})
+	return reminders, err
+}
+
+// MarkLoanReminded records that a reminder was sent
+func (m *Manager) MarkLoanReminded(id, kind string) error {
+	_, err := m.updateLoan(id, func(l *Loan) error {
+		switch kind {
+		case ReminderBefore:
+			l.RemindedBefore = true
+		case ReminderDue:
+			// A due reminder supersedes the advance one
+			l.RemindedBefore = true
+			l.RemindedDue = true
+		default:
+			return fmt.Errorf("unknown reminder kind %q", kind)
+		}
+		return nil
+	})
+	return err
+}
@@ -1,9 +1,9 @@
 package manga
 
 import (
-	"encoding/json"
-	"fmt"
+	"time"
 
+	"github.com/kench/komikan-go/internal/api"
 	"github.com/kench/komikan-go/internal/db"
 )
 
@@ -19,16 +19,79 @@ type Manga struct {
 	PublishDate string   `json:"publish_date"`
 	URL         string   `json:"url"` // Purchase URL
 	Tags        []string `json:"tags,omitempty"`
+	Status      string   `json:"status,omitempty"` // owned, wishlist or preorder
+	Notes       string   `json:"notes,omitempty"`
+
+	// Cover image URLs as served by Rakuten
+	CoverSmall  string `json:"cover_small,omitempty"`
+	CoverMedium string `json:"cover_medium,omitempty"`
+	CoverLarge  string `json:"cover_large,omitempty"`
+	CoverHash   string `json:"cover_hash,omitempty"` // Content hash in the local cover cache
+
+	AddedAt time.Time `json:"added_at,omitempty"` // When registered in the collection
+}
+
+// Status values for Manga.Status
+const (
+	StatusOwned    = "owned"
+	StatusWishlist = "wishlist"
+	StatusPreorder = "preorder"
+)
+
+// FromBookInfo builds a Manga entry from Rakuten book information
+// Series and volume are filled in when the title carries a volume number
+func FromBookInfo(book api.BookInfo) Manga {
+	m := Manga{
+		ID:          book.Isbn,
+		Title:       book.Title,
+		Author:      book.Author,
+		Publisher:   book.Publisher,
+		ISBN:        book.Isbn,
+		PublishDate: book.SalesDate,
+		URL:         book.ItemURL,
+		Status:      StatusOwned,
+		CoverSmall:  book.SmallImage,
+		CoverMedium: book.MediumImage,
+		CoverLarge:  book.LargeImage,
+	}
+
+	volInfo := ExtractVolumeInfo(book.Title)
+	if volInfo.HasVolume {
+		m.Volume = volInfo.Volume
+		m.Series = volInfo.Title
+	}
+
+	return m
 }
 
 // Manager manages manga collection
 type Manager struct {
-	db *db.DB
+	db     *db.DB // The library: collection, reading, loans, follows...
+	shared *db.DB // Bot-wide records such as subscriptions
+	user   string // Owner of a member library, empty for the shared one
 }
 
-// NewManager creates a new manga manager
+// NewManager creates a new manga manager for the shared library
 func NewManager(database *db.DB) *Manager {
-	return &Manager{db: database}
+	return &Manager{db: database, shared: database}
+}
+
+// userNamespace prefixes the namespace of a member library
+const userNamespace = "user-"
+
+// ForUser returns the manager of a member's own library
+// Members are identified by hex public key; each library lives in its
+// own namespace of the same database
+func (m *Manager) ForUser(pubkey string) *Manager {
+	if pubkey == "" {
+		return NewManager(m.shared)
+	}
+	return &Manager{db: m.shared.Namespace(userNamespace + pubkey), shared: m.shared, user: pubkey}
+}
+
+// User returns the public key owning the library, empty for the shared one
+func (m *Manager) User() string {
+	return m.user
 }
 
 // Add adds a manga to the collection
@@ -36,16 +99,37 @@ func (m *Manager) Add(manga Manga) error {
 	if manga.ID == "" {
 		manga.ID = manga.ISBN
 	}
+	if manga.Status == "" {
+		manga.Status = StatusOwned
+	}
 
-	key := fmt.Sprintf("manga:isbn:%s", manga.ISBN)
-	return m.db.SetJSON(key, manga)
+	return m.db.Update(func(txn *db.Txn) error {
+		// Keep the original registration date on updates
+		if manga.AddedAt.IsZero() {
+			existing, err := mangaRecords.Get(txn, manga.ISBN)
+			switch {
+			case err == nil:
+				manga.AddedAt = existing.AddedAt
+			case !db.IsNotFound(err):
+				return err
+			}
+		}
+		if manga.AddedAt.IsZero() {
+			manga.AddedAt = time.Now()
+		}
+		return mangaRecords.Put(txn, manga)
+	})
 }
 
 // GetByISBN retrieves a manga by ISBN
 func (m *Manager) GetByISBN(isbn string) (*Manga, error) {
-	key := fmt.Sprintf("manga:isbn:%s", isbn)
 	var manga Manga
-	if err := m.db.GetJSON(key, &manga); err != nil {
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		manga, err = mangaRecords.Get(txn, isbn)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &manga, nil
@@ -53,12 +137,7 @@ func (m *Manager) GetByISBN(isbn string) (*Manga, error) {
 
 // GetBySeries returns all manga in a series
 func (m *Manager) GetBySeries(series string) ([]Manga, error) {
-	key := fmt.Sprintf("manga:series:%s", series)
-	var mangaList []Manga
-	if err := m.db.GetJSON(key, &mangaList); err != nil {
-		return nil, err
-	}
-	return mangaList, nil
+	return m.findBy(bySeries, series)
 }
 
 // GetLatestVolume returns the latest volume for a given series/title
@@ -79,70 +158,43 @@ func (m *Manager) GetLatestVolume(series string) (int, error) {
 }
 
 // AddToSeries adds a manga to a series index
+// The series index is maintained by Add; this stores the entry if needed
 func (m *Manager) AddToSeries(manga Manga) error {
 	if manga.Series == "" {
 		return nil // No series to update
 	}
-
-	// Get existing series list
-	key := fmt.Sprintf("manga:series:%s", manga.Series)
-	var mangaList []Manga
-	if err := m.db.GetJSON(key, &mangaList); err != nil {
-		// First entry in series
-		mangaList = []Manga{manga}
-		return m.db.SetJSON(key, mangaList)
-	}
-
-	// Check if already exists
-	for i, existing := range mangaList {
-		if existing.ISBN == manga.ISBN {
-			// Update existing entry
-			mangaList[i] = manga
-			return m.db.SetJSON(key, mangaList)
-		}
-	}
-
-	// Add new entry
-	mangaList = append(mangaList, manga)
-	return m.db.SetJSON(key, mangaList)
+	return m.Add(manga)
 }
 
 // List returns all manga in the collection
 func (m *Manager) List() ([]Manga, error) {
-	values, err := m.db.ListPrefixJSON("manga:isbn:")
+	page, err := m.Query(db.Query[Manga]{})
 	if err != nil {
 		return nil, err
 	}
+	return page.Items, nil
+}
 
-	mangaList := make([]Manga, 0, len(values))
-	for _, v := range values {
-		var m Manga
-		if err := json.Unmarshal(v, &m); err != nil {
-			continue // Skip invalid entries
-		}
-		mangaList = append(mangaList, m)
-	}
-
-	return mangaList, nil
+// Query returns manga matching a filter, in the requested order and page
+func (m *Manager) Query(q db.Query[Manga]) (db.Page[Manga], error) {
+	var page db.Page[Manga]
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		page, err = mangaRecords.Scan(txn, q)
+		return err
+	})
+	return page, err
 }
 
 // ListSeries returns all series names
 func (m *Manager) ListSeries() ([]string, error) {
-	keys, err := m.db.ListPrefix("manga:series:")
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract series names from keys
-	series := make([]string, 0, len(keys))
-	prefixLen := len("manga:series:")
-	for _, key := range keys {
-		if len(key) > prefixLen {
-			series = append(series, string(key[prefixLen:]))
-		}
-	}
-
-	return series, nil
+	var series []string
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		series, err = bySeries.Values(txn)
+		return err
+	})
+	return series, err
 }
 
 // Update updates a manga entry
@@ -150,8 +202,15 @@ func (m *Manager) Update(manga Manga) error {
 	return m.Add(manga)
 }
 
-// Delete removes a manga from the collection
+// Delete removes a manga with its reading record and price history
 func (m *Manager) Delete(isbn string) error {
-	key := fmt.Sprintf("manga:isbn:%s", isbn)
-	return m.db.Delete([]byte(key))
+	return m.db.Update(func(txn *db.Txn) error {
+		if err := readingRecords.Delete(txn, isbn); err != nil {
+			return err
+		}
+		if err := priceRecords.Delete(txn, isbn); err != nil {
+			return err
+		}
+		return mangaRecords.Delete(txn, isbn)
+	})
 }
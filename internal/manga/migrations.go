@@ -0,0 +1,80 @@
+package manga
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version: 2,
+		Name:    "status field and secondary indexes replace series lists",
+		Up:      migrateSeriesIndex,
+	})
+	db.RegisterMigration(db.Migration{
+		Version: 3,
+		Name:    "full-text search index",
+		Up:      mangaRecords.Reindex,
+	})
+}
+
+// migrateSeriesIndex moves from per-series JSON arrays to index entries
+// Entries only present in a series list are kept as records, records
+// without a status are marked owned, and all indexes are built. Each step
+// can be repeated, so an interrupted migration is simply run again.
+// Records that cannot be decoded are left as they are and logged.
+func migrateSeriesIndex(d *db.DB) error {
+	skipped := 0
+	err := d.UpdateEach("manga:series:", func(txn *db.Txn, e db.Entry) error {
+		var list []Manga
+		if err := json.Unmarshal(e.Value, &list); err != nil {
+			log.Printf("Migration: skipping %s: %v", e.Key, err)
+			skipped++
+			return nil
+		}
+		for _, mg := range list {
+			if mg.ISBN == "" {
+				continue
+			}
+			if _, err := mangaRecords.Get(txn, mg.ISBN); db.IsNotFound(err) {
+				if mg.Series == "" {
+					mg.Series = strings.TrimPrefix(e.Key, "manga:series:")
+				}
+				if err := txn.SetJSON(mangaRecords.Key(mg.ISBN), mg); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+		}
+		return txn.Delete([]byte(e.Key))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = d.UpdateEach("manga:isbn:", func(txn *db.Txn, e db.Entry) error {
+		var mg Manga
+		if err := json.Unmarshal(e.Value, &mg); err != nil {
+			log.Printf("Migration: skipping %s: %v", e.Key, err)
+			skipped++
+			return nil
+		}
+		if mg.Status != "" {
+			return nil
+		}
+		mg.Status = StatusOwned
+		return txn.SetJSON(e.Key, mg)
+	})
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		log.Printf("Migration: %d undecodable records were skipped", skipped)
+	}
+
+	return mangaRecords.Reindex(d)
+}
@@ -0,0 +1,112 @@
+package manga
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// seedLibrary writes n manga in the given schema version's layout,
+// without index entries, as an older build would have left them
+func seedLibrary(t *testing.T, path string, opts db.BadgerOptions, version, n int) {
+	t.Helper()
+	d, err := db.NewDB(db.Config{Path: path, Badger: opts, SkipMigrations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for start := 0; start < n; start += 200 {
+		err := d.Update(func(txn *db.Txn) error {
+			for i := start; i < min(start+200, n); i++ {
+				mg := Manga{
+					ISBN:      fmt.Sprintf("978%010d", i),
+					Title:     fmt.Sprintf("進撃の巨人 第%d巻 特装版", i),
+					Series:    fmt.Sprintf("進撃の巨人 %d", i%40),
+					Author:    "諫山創",
+					Publisher: "講談社",
+					Volume:    i,
+					Status:    StatusOwned,
+					Notes:     "限定版の小冊子付き。ブックカバーは書店オリジナル",
+				}
+				if err := txn.SetJSON(mangaRecords.Key(mg.ISBN), mg); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.SetSchemaVersion(version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchMigrationOnLargeLibrary(t *testing.T) {
+	for name, opts := range map[string]db.BadgerOptions{
+		"defaults":    {},
+		"memtable 16": {MemTableSizeMB: 16},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "komikan.db")
+			seedLibrary(t, path, opts, 2, 3000)
+
+			d, err := db.NewDB(db.Config{Path: path, Badger: opts})
+			if err != nil {
+				t.Fatalf("opening with migration 3 pending: %v", err)
+			}
+			defer d.Close()
+
+			if v, err := d.SchemaVersionOf(); err != nil || v != db.SchemaVersion {
+				t.Fatalf("schema version = %d, %v; want %d", v, err, db.SchemaVersion)
+			}
+			results, err := NewManager(d).Search("特装版", 5000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 3000 {
+				t.Errorf("search found %d manga, want 3000", len(results))
+			}
+		})
+	}
+}
+
+func TestSeriesMigrationSkipsCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "komikan.db")
+	seedLibrary(t, path, db.BadgerOptions{}, 1, 10)
+
+	d, err := db.NewDB(db.Config{Path: path, SkipMigrations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	err = d.Update(func(txn *db.Txn) error {
+		list := []Manga{{ISBN: "9780000000999", Title: "リストのみ"}}
+		if err := txn.SetJSON("manga:series:リスト", list); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte("manga:series:壊れた"), []byte("[{")); err != nil {
+			return err
+		}
+		return txn.Set([]byte(mangaRecords.Key("9780000000998")), []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Migrate(db.MigrateOptions{}); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := d.SchemaVersionOf(); v != db.SchemaVersion {
+		t.Errorf("schema version = %d, want %d", v, db.SchemaVersion)
+	}
+
+	var mg Manga
+	if err := d.GetJSON(mangaRecords.Key("9780000000999"), &mg); err != nil || mg.Series != "リスト" || mg.Status != StatusOwned {
+		t.Errorf("series list entry = %+v, %v; want a record in series リスト marked owned", mg, err)
+	}
+}
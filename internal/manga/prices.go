@@ -0,0 +1,205 @@
+package manga
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kench/komikan-go/internal/api"
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// maxPricePoints bounds the history kept per volume
+const maxPricePoints = 200
+
+// PricePoint is one observation of a volume's price on Rakuten
+type PricePoint struct {
+	CheckedAt    time.Time `json:"checked_at"`
+	Price        int       `json:"price"` // Yen including tax
+	PointRate    int       `json:"point_rate"`
+	Availability string    `json:"availability"`
+	Limited      bool      `json:"limited,omitempty"`
+}
+
+// State returns the stock state derived from the availability code
+func (p PricePoint) State() string {
+	return api.StockState(p.Availability)
+}
+
+// PriceHistory is the observed price and stock history of a volume
+// A new point is only appended when price, points or stock change
+type PriceHistory struct {
+	ISBN   string       `json:"isbn"`
+	Points []PricePoint `json:"points"`
+}
+
+// Latest returns the most recent observation
+func (h PriceHistory) Latest() (PricePoint, bool) {
+	if len(h.Points) == 0 {
+		return PricePoint{}, false
+	}
+	return h.Points[len(h.Points)-1], true
+}
+
+// priceRecords stores price histories keyed by ISBN
+var priceRecords = db.NewCollection[PriceHistory]("price:isbn:", func(h PriceHistory) string {
+	return h.ISBN
+})
+
+// Price alert kinds
+const (
+	AlertPriceDrop   = "price_drop"
+	AlertBonusPoints = "bonus_points"
+	AlertRestock     = "restock"
+	AlertPreorder    = "preorder_open" // Became available for preorder
+	AlertLowStock    = "low_stock"     // Limited edition is running out
+	AlertSoldOut     = "sold_out"      // Limited edition is no longer sold
+)
+
+// IsPriceAlert reports whether a kind is about price rather than stock
+func IsPriceAlert(kind string) bool {
+	return kind == AlertPriceDrop || kind == AlertBonusPoints
+}
+
+// PriceAlert reports a change worth telling the owner about
+type PriceAlert struct {
+	Kind     string
+	Manga    Manga
+	Previous PricePoint
+	Current  PricePoint
+}
+
+// GetPriceHistory returns the price history of a volume
+func (m *Manager) GetPriceHistory(isbn string) (PriceHistory, error) {
+	var h PriceHistory
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		h, err = priceRecords.Get(txn, isbn)
+		return err
+	})
+	return h, err
+}
+
+// RecordPrice stores an observation and returns the alerts it triggers
+// The first observation of a volume only alerts when it is preorderable
+func (m *Manager) RecordPrice(mg Manga, book api.BookInfo, now time.Time) ([]PriceAlert, error) {
+	cur := PricePoint{
+		CheckedAt:    now,
+		Price:        book.ItemPrice,
+		PointRate:    book.PointRate,
+		Availability: book.Availability,
+		Limited:      book.Limited(),
+	}
+
+	var alerts []PriceAlert
+	err := m.db.Update(func(txn *db.Txn) error {
+		h, err := priceRecords.Get(txn, mg.ISBN)
+		if db.IsNotFound(err) {
+			h = PriceHistory{ISBN: mg.ISBN}
+		} else if err != nil {
+			return err
+		}
+
+		prev, ok := h.Latest()
+		switch {
+		case !ok:
+			if cur.State() == api.StockPreorder {
+				alerts = []PriceAlert{{Kind: AlertPreorder, Manga: mg, Current: cur}}
+			}
+		case prev.Price == cur.Price && prev.PointRate == cur.PointRate &&
+			prev.Availability == cur.Availability && prev.Limited == cur.Limited:
+			return nil
+		default:
+			alerts = priceAlerts(mg, prev, cur)
+		}
+
+		h.Points = append(h.Points, cur)
+		if len(h.Points) > maxPricePoints {
+			h.Points = h.Points[len(h.Points)-maxPricePoints:]
+		}
+		return priceRecords.Put(txn, h)
+	})
+	return alerts, err
+}
+
+// priceAlerts compares two observations
+func priceAlerts(mg Manga, prev, cur PricePoint) []PriceAlert {
+	var alerts []PriceAlert
+	add := func(kind string) {
+		alerts = append(alerts, PriceAlert{Kind: kind, Manga: mg, Previous: prev, Current: cur})
+	}
+
+	if prev.Price > 0 && cur.Price > 0 && cur.Price < prev.Price {
+		add(AlertPriceDrop)
+	}
+	if cur.PointRate > 1 && cur.PointRate > prev.PointRate {
+		add(AlertBonusPoints)
+	}
+
+	before, after := prev.State(), cur.State()
+	switch {
+	case before == after:
+	case after == api.StockInStock && before != api.StockPreorder:
+		add(AlertRestock)
+	case after == api.StockPreorder:
+		add(AlertPreorder)
+	case cur.Limited && after == api.StockUnavailable:
+		add(AlertSoldOut)
+	case cur.Limited && stockRank(after) < stockRank(before):
+		// Rakuten has no stock counts, so a limited edition dropping
+		// to a slower shipping state is the sign it is selling out
+		add(AlertLowStock)
+	}
+	return alerts
+}
+
+// stockRank orders stock states from least to most available
+func stockRank(state string) int {
+	switch state {
+	case api.StockInStock, api.StockPreorder:
+		return 3
+	case api.StockShipsLater:
+		return 2
+	case api.StockBackorder:
+		return 1
+	}
+	return 0
+}
+
+// CheckPrices looks up every wishlist and preorder volume and records its price
+// Requests are spaced by interval to respect the provider's rate limit
+func (m *Manager) CheckPrices(provider api.Provider, interval time.Duration) ([]PriceAlert, error) {
+	var targets []Manga
+	for _, status := range []string{StatusWishlist, StatusPreorder} {
+		list, err := m.FindByStatus(status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s manga: %w", status, err)
+		}
+		targets = append(targets, list...)
+	}
+
+	var alerts []PriceAlert
+	for i, mg := range targets {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+
+		book, err := provider.SearchByISBN(mg.ISBN)
+		if err != nil {
+			log.Printf("Failed to look up price of %s: %v", mg.ISBN, err)
+			continue
+		}
+		// ISBN search sometimes returns a different book
+		if book.Isbn != mg.ISBN {
+			log.Printf("Skipping price of %s: lookup returned %s", mg.ISBN, book.Isbn)
+			continue
+		}
+
+		found, err := m.RecordPrice(mg, *book, time.Now())
+		if err != nil {
+			return alerts, err
+		}
+		alerts = append(alerts, found...)
+	}
+	return alerts, nil
+}
@@ -0,0 +1,294 @@
+package manga
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// Purchase formats
+const (
+	FormatPhysical = "physical"
+	FormatDigital  = "digital"
+)
+
+// DefaultCurrency is used when a purchase has no currency
+const DefaultCurrency = "JPY"
+
+// Purchase records what was actually paid for a volume
+// Amounts are in the currency's minor unit (yen, cents)
+type Purchase struct {
+	ID       string `json:"id"`
+	ISBN     string `json:"isbn"`
+	Price    int64  `json:"price"`  // Including tax
+	Points   int64  `json:"points"` // Point rebate, valued in the same currency
+	Currency string `json:"currency"`
+	Store    string `json:"store,omitempty"`
+	Format   string `json:"format"` // physical or digital
+	Date     string `json:"date"`   // YYYY-MM-DD
+	Notes    string `json:"notes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Net returns the price after point rebates
+func (p Purchase) Net() int64 {
+	return p.Price - p.Points
+}
+
+// purchaseRecords stores purchases keyed by ID
+var purchaseRecords = db.NewCollection[Purchase]("purchase:id:", func(p Purchase) string {
+	return p.ID
+})
+
+// byPurchaseISBN indexes purchases by volume
+var byPurchaseISBN = purchaseRecords.AddIndex("isbn", func(p Purchase) []string {
+	return []string{p.ISBN}
+})
+
+// byPurchaseDate indexes purchases by purchase date
+var byPurchaseDate = purchaseRecords.AddIndex("date", func(p Purchase) []string {
+	return []string{p.Date}
+})
+
+// currencyDigits returns the number of minor unit digits for a currency
+func currencyDigits(currency string) int {
+	switch currency {
+	case "JPY", "KRW":
+		return 0
+	}
+	return 2
+}
+
+// ParsePrice parses a decimal amount such as "1.99" into minor units
+func ParsePrice(s, currency string) (int64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return int64(math.Round(f * math.Pow10(currencyDigits(currency)))), nil
+}
+
+// FormatAmount formats an amount in minor units as a plain decimal
+func FormatAmount(amount int64, currency string) string {
+	digits := currencyDigits(currency)
+	if digits == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+	return strconv.FormatFloat(float64(amount)/math.Pow10(digits), 'f', digits, 64)
+}
+
+// FormatPrice formats an amount in minor units with its currency
+func FormatPrice(amount int64, currency string) string {
+	return FormatAmount(amount, currency) + " " + currency
+}
+
+// AddPurchase records a purchase of a registered volume
+func (m *Manager) AddPurchase(p Purchase) (Purchase, error) {
+	if _, err := m.GetByISBN(p.ISBN); err != nil {
+		return Purchase{}, fmt.Errorf("manga %s is not registered", p.ISBN)
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	p.Currency = strings.ToUpper(p.Currency)
+	if p.Format == "" {
+		p.Format = FormatPhysical
+	}
+	if p.Format != FormatPhysical && p.Format != FormatDigital {
+		return Purchase{}, fmt.Errorf("invalid format %q: use physical or digital", p.Format)
+	}
+	if p.Price < 0 || p.Points < 0 {
+		return Purchase{}, fmt.Errorf("price and points must not be negative")
+	}
+	var err error
+	if p.Date, err = readingDate(p.Date); err != nil {
+		return Purchase{}, err
+	}
+
+	p.CreatedAt = time.Now()
+	p.ID = strconv.FormatInt(p.CreatedAt.UnixNano(), 36)
+
+	err = m.db.Update(func(txn *db.Txn) error {
+		return purchaseRecords.Put(txn, p)
+	})
+	return p, err
+}
+
+// DeletePurchase removes a purchase record
+func (m *Manager) DeletePurchase(id string) error {
+	return m.db.Update(func(txn *db.Txn) error {
+		if _, err := purchaseRecords.Get(txn, id); db.IsNotFound(err) {
+			return fmt.Errorf("purchase %s not found", id)
+		}
+		return purchaseRecords.Delete(txn, id)
+	})
+}
+
+// ListPurchases returns purchases in date order, optionally for one volume
+func (m *Manager) ListPurchases(isbn string) ([]Purchase, error) {
+	var purchases []Purchase
+	err := m.db.View(func(txn *db.Txn) error {
+		var ids []string
+		var err error
+		if isbn != "" {
+			ids, err = byPurchaseISBN.Lookup(txn, isbn)
+		} else {
+			ids, err = byPurchaseDate.Range(txn, "", "")
+		}
+		if err != nil {
+			return err
+		}
+		purchases, err = purchaseRecords.GetMany(txn, ids)
+		return err
+	})
+
+	sort.SliceStable(purchases, func(i, j int) bool {
+		return purchases[i].Date < purchases[j].Date
+	})
+	return purchases, err
+}
+
+// Spend report groupings
+var SpendGroups = []string{"month", "series", "publisher", "store", "format"}
+
+// SpendOptions selects the purchases in a spend report
+type SpendOptions struct {
+	By   string // One of SpendGroups
+	From string // Purchase date lower bound, inclusive (YYYY, YYYY-MM or YYYY-MM-DD)
+	To   string // Purchase date upper bound, inclusive
+}
+
+// SpendRow is one group of a spend report
+// Amounts in different currencies are reported in separate rows
+type SpendRow struct {
+	Key      string
+	Currency string
+	Count    int
+	Total    int64 // Paid including tax
+	Points   int64
+}
+
+// Net returns the total after point rebates
+func (r SpendRow) Net() int64 {
+	return r.Total - r.Points
+}
+
+// SpendReport totals purchases by the requested grouping
+// Month rows are in date order, other groupings by total spent per currency
+func (m *Manager) SpendReport(opts SpendOptions) ([]SpendRow, error) {
+	if opts.By == "" {
+		opts.By = "month"
+	}
+	valid := false
+	for _, g := range SpendGroups {
+		valid = valid || g == opts.By
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid grouping %q: use %s", opts.By, strings.Join(SpendGroups, ", "))
+	}
+
+	from := NormalizeDate(opts.From)
+	if opts.From != "" && from == "" {
+		return nil, fmt.Errorf("invalid date %q", opts.From)
+	}
+	to := NormalizeDate(opts.To)
+	if opts.To != "" {
+		if to == "" {
+			return nil, fmt.Errorf("invalid date %q", opts.To)
+		}
+		// Make a partial upper bound cover the whole month or year
+		to += "\xff"
+	}
+
+	rows := map[string]*SpendRow{}
+	err := m.db.View(func(txn *db.Txn) error {
+		ids, err := byPurchaseDate.Range(txn, from, to)
+		if err != nil {
+			return err
+		}
+		purchases, err := purchaseRecords.GetMany(txn, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range purchases {
+			key, err := spendKey(txn, opts.By, p)
+			if err != nil {
+				return err
+			}
+			row := rows[key+"\x00"+p.Currency]
+			if row == nil {
+				row = &SpendRow{Key: key, Currency: p.Currency}
+				rows[key+"\x00"+p.Currency] = row
+			}
+			row.Count++
+			row.Total += p.Price
+			row.Points += p.Points
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]SpendRow, 0, len(rows))
+	for _, r := range rows {
+		report = append(report, *r)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if opts.By == "month" && a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		// Totals are only comparable within a currency
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+// spendKey returns the group a purchase is reported under
+func spendKey(txn *db.Txn, by string, p Purchase) (string, error) {
+	switch by {
+	case "month":
+		return p.Date[:7], nil
+	case "store":
+		if p.Store == "" {
+			return "(unknown)", nil
+		}
+		return p.Store, nil
+	case "format":
+		return p.Format, nil
+	}
+
+	mg, err := mangaRecords.Get(txn, p.ISBN)
+	if db.IsNotFound(err) {
+		return "(deleted)", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	key := mg.Publisher
+	if by == "series" {
+		key = mg.Series
+		if key == "" {
+			key = mg.Title
+		}
+	}
+	if key == "" {
+		key = "(unknown)"
+	}
+	return key, nil
+}
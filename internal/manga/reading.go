@@ -0,0 +1,293 @@
+package manga
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// Reading status values
+// Volumes without a reading record are unread
+const (
+	ReadingUnread  = "unread"
+	ReadingReading = "reading"
+	ReadingRead    = "read"
+	ReadingDropped = "dropped"
+)
+
+// ReadingRecord is the personal reading log of one volume
+type ReadingRecord struct {
+	ISBN      string           `json:"isbn"`
+	Status    string           `json:"status"`
+	Sessions  []ReadingSession `json:"sessions,omitempty"` // One per read-through
+	Rating    int              `json:"rating,omitempty"`   // 1-5, 0 if unrated
+	Notes     string           `json:"notes,omitempty"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// ReadingSession is a single read-through of a volume
+// Dates are YYYY-MM-DD; Finished is empty while reading or if dropped
+type ReadingSession struct {
+	Started  string `json:"started,omitempty"`
+	Finished string `json:"finished,omitempty"`
+}
+
+// ReadCount returns how many times the volume has been finished
+func (r ReadingRecord) ReadCount() int {
+	n := 0
+	for _, s := range r.Sessions {
+		if s.Finished != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// readingRecords stores reading records keyed by ISBN
+var readingRecords = db.NewCollection[ReadingRecord]("reading:isbn:", func(r ReadingRecord) string {
+	return r.ISBN
+})
+
+// byReadingStatus indexes reading records by status
+var byReadingStatus = readingRecords.AddIndex("status", func(r ReadingRecord) []string {
+	return []string{r.Status}
+})
+
+// GetReading returns the reading record of a volume
+// Volumes never started return an unread record
+func (m *Manager) GetReading(isbn string) (ReadingRecord, error) {
+	var rec ReadingRecord
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		rec, err = getReading(txn, isbn)
+		return err
+	})
+	return rec, err
+}
+
+func getReading(txn *db.Txn, isbn string) (ReadingRecord, error) {
+	rec, err := readingRecords.Get(txn, isbn)
+	if db.IsNotFound(err) {
+		return ReadingRecord{ISBN: isbn, Status: ReadingUnread}, nil
+	}
+	return rec, err
+}
+
+// updateReading applies fn to a volume's reading record and stores it
+// The volume must be in the collection
+func (m *Manager) updateReading(isbn string, fn func(rec *ReadingRecord) error) (ReadingRecord, error) {
+	var rec ReadingRecord
+	err := m.db.Update(func(txn *db.Txn) error {
+		if _, err := mangaRecords.Get(txn, isbn); err != nil {
+			if db.IsNotFound(err) {
+				return fmt.Errorf("manga %s is not registered", isbn)
+			}
+			return err
+		}
+
+		var err error
+		rec, err = getReading(txn, isbn)
+		if err != nil {
+			return err
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+		rec.UpdatedAt = time.Now()
+		return readingRecords.Put(txn, rec)
+	})
+	return rec, err
+}
+
+// StartReading starts a read-through on date (YYYY-MM-DD, today if empty)
+// Starting a finished volume again records a re-read
+func (m *Manager) StartReading(isbn, date string) (ReadingRecord, error) {
+	date, err := readingDate(date)
+	if err != nil {
+		return ReadingRecord{}, err
+	}
+	return m.updateReading(isbn, func(rec *ReadingRecord) error {
+		if rec.Status == ReadingReading {
+			return fmt.Errorf("already reading since %s", rec.Sessions[len(rec.Sessions)-1].Started)
+		}
+		rec.Status = ReadingReading
+		rec.Sessions = append(rec.Sessions, ReadingSession{Started: date})
+		return nil
+	})
+}
+
+// FinishReading finishes the current read-through on date (today if empty)
+// A volume finished without being started gets a single-day session
+func (m *Manager) FinishReading(isbn, date string) (ReadingRecord, error) {
+	date, err := readingDate(date)
+	if err != nil {
+		return ReadingRecord{}, err
+	}
+	return m.updateReading(isbn, func(rec *ReadingRecord) error {
+		if rec.Status != ReadingReading {
+			rec.Sessions = append(rec.Sessions, ReadingSession{Started: date})
+		}
+		rec.Sessions[len(rec.Sessions)-1].Finished = date
+		rec.Status = ReadingRead
+		return nil
+	})
+}
+
+// DropReading marks a volume as dropped
+func (m *Manager) DropReading(isbn string) (ReadingRecord, error) {
+	return m.updateReading(isbn, func(rec *ReadingRecord) error {
+		rec.Status = ReadingDropped
+		return nil
+	})
+}
+
+// RateReading sets a 1-5 rating, or clears it with 0
+func (m *Manager) RateReading(isbn string, rating int) (ReadingRecord, error) {
+	if rating < 0 || rating > 5 {
+		return ReadingRecord{}, fmt.Errorf("rating must be between 1 and 5")
+	}
+	return m.updateReading(isbn, func(rec *ReadingRecord) error {
+		rec.Rating = rating
+		return nil
+	})
+}
+
+// SetReadingNotes replaces the free-text reading notes of a volume
+func (m *Manager) SetReadingNotes(isbn, notes string) (ReadingRecord, error) {
+	return m.updateReading(isbn, func(rec *ReadingRecord) error {
+		rec.Notes = notes
+		return nil
+	})
+}
+
+// readingDate validates a YYYY-MM-DD date, defaulting to today
+func readingDate(date string) (string, error) {
+	if date == "" {
+		return time.Now().Format("2006-01-02"), nil
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", fmt.Errorf("invalid date %q: use YYYY-MM-DD", date)
+	}
+	return date, nil
+}
+
+// SeriesProgress is the aggregate reading progress of a series
+type SeriesProgress struct {
+	Series  string
+	Owned   int
+	Read    int
+	Reading int
+	Dropped int
+}
+
+// Unread returns the number of owned volumes not yet started
+func (p SeriesProgress) Unread() int {
+	return p.Owned - p.Read - p.Reading - p.Dropped
+}
+
+// ReadingProgress returns reading progress for every series with owned
+// volumes, or only the given series
+func (m *Manager) ReadingProgress(series string) ([]SeriesProgress, error) {
+	var progress []SeriesProgress
+	err := m.db.View(func(txn *db.Txn) error {
+		var names []string
+		if series != "" {
+			names = []string{series}
+		} else {
+			var err error
+			if names, err = bySeries.Values(txn); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range names {
+			volumes, err := bySeries.Find(txn, name)
+			if err != nil {
+				return err
+			}
+			p := SeriesProgress{Series: name}
+			for _, v := range volumes {
+				if !isOwned(v) {
+					continue
+				}
+				p.Owned++
+				rec, err := getReading(txn, v.ISBN)
+				if err != nil {
+					return err
+				}
+				switch rec.Status {
+				case ReadingRead:
+					p.Read++
+				case ReadingReading:
+					p.Reading++
+				case ReadingDropped:
+					p.Dropped++
+				}
+			}
+			if p.Owned > 0 {
+				progress = append(progress, p)
+			}
+		}
+		return nil
+	})
+	return progress, err
+}
+
+// isOwned reports whether a manga is in the owned collection
+// Records from before statuses existed count as owned
+func isOwned(m Manga) bool {
+	return m.Status == "" || m.Status == StatusOwned
+}
+
+// Tsundoku returns owned volumes that have never been started (積読),
+// oldest first by registration date, then publish date
+func (m *Manager) Tsundoku() ([]Manga, error) {
+	var unread []Manga
+	err := m.db.View(func(txn *db.Txn) error {
+		// A rating or notes alone leave a record that is still unread
+		started := make(map[string]bool)
+		for _, status := range []string{ReadingReading, ReadingRead, ReadingDropped} {
+			list, err := byReadingStatus.Find(txn, status)
+			if err != nil {
+				return err
+			}
+			for _, rec := range list {
+				started[rec.ISBN] = true
+			}
+		}
+
+		owned, err := mangaRecords.Scan(txn, db.Query[Manga]{Filter: func(mg Manga) bool {
+			return isOwned(mg) && !started[mg.ISBN]
+		}})
+		if err != nil {
+			return err
+		}
+		unread = owned.Items
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(unread, func(i, j int) bool {
+		a, b := unread[i], unread[j]
+		if !a.AddedAt.Equal(b.AddedAt) {
+			return a.AddedAt.Before(b.AddedAt)
+		}
+		return NormalizeDate(a.PublishDate) < NormalizeDate(b.PublishDate)
+	})
+	return unread, nil
+}
+
+// FindByReadingStatus returns the reading records with a status
+func (m *Manager) FindByReadingStatus(status string) ([]ReadingRecord, error) {
+	var list []ReadingRecord
+	err := m.db.View(func(txn *db.Txn) error {
+		var err error
+		list, err = byReadingStatus.Find(txn, status)
+		return err
+	})
+	return list, err
+}
@@ -0,0 +1,47 @@
+package manga
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+func TestTsundokuKeepsRatedUnreadVolumes(t *testing.T) {
+	d, err := db.NewDB(db.Config{Path: filepath.Join(t.TempDir(), "komikan.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	m := NewManager(d)
+
+	for _, isbn := range []string{"9780000000001", "9780000000002", "9780000000003", "9780000000004"} {
+		if err := m.Add(Manga{ISBN: isbn, Title: isbn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.RateReading("9780000000001", 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetReadingNotes("9780000000002", "表紙が好き"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.StartReading("9780000000003", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := m.Tsundoku()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mg := range list {
+		got = append(got, mg.ISBN)
+	}
+	slices.Sort(got)
+	want := []string{"9780000000001", "9780000000002", "9780000000004"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tsundoku = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package manga
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// searchFields lists the indexed fields with their field code and weight
+// Matches in titles rank above matches in notes
+var searchFields = []struct {
+	code   string
+	weight int
+	text   func(m Manga) string
+}{
+	{"t", 5, func(m Manga) string { return m.Title }},
+	{"s", 4, func(m Manga) string { return m.Series }},
+	{"a", 3, func(m Manga) string { return m.Author }},
+	{"g", 2, func(m Manga) string { return strings.Join(m.Tags, " ") }},
+	{"p", 1, func(m Manga) string { return m.Publisher }},
+	{"n", 1, func(m Manga) string { return m.Notes }},
+}
+
+// bySearch is an n-gram index over searchFields
+// Values are "<field code>:<bigram>", so one posting list exists per field
+var bySearch = mangaRecords.AddIndex("search", func(m Manga) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, f := range searchFields {
+		for _, gram := range bigrams(NormalizeText(f.text(m))) {
+			v := f.code + ":" + gram
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+})
+
+// NormalizeText folds text for matching
+// NFKC unifies full-width and half-width forms (ＡＢＣ, ｶﾀｶﾅ), katakana is
+// folded to hiragana, letters are lower-cased and whitespace is dropped
+func NormalizeText(s string) string {
+	s = norm.NFKC.String(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r >= 'ァ' && r <= 'ヶ':
+			r -= 'ァ' - 'ぁ'
+		default:
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bigrams splits normalized text into overlapping two-character grams
+// This works for Japanese, which has no word boundaries
+func bigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+// SearchResult is a ranked search hit
+type SearchResult struct {
+	Manga Manga
+	Score int
+}
+
+// Search finds manga whose title, series, author, tags, publisher or notes
+// contain the query, ranked by field weight and match quality
+func (m *Manager) Search(query string, limit int) ([]SearchResult, error) {
+	q := NormalizeText(query)
+	if q == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+
+	// Single characters have no bigrams; fall back to scanning
+	if len([]rune(q)) < 2 {
+		list, err := m.List()
+		if err != nil {
+			return nil, err
+		}
+		return rankResults(list, q, limit), nil
+	}
+
+	grams := uniqueStrings(bigrams(q))
+	var candidates []Manga
+	err := m.db.View(func(txn *db.Txn) error {
+		// Count how many query grams each record contains in any field
+		hits := make(map[string]int)
+		for _, gram := range grams {
+			seen := make(map[string]bool)
+			for _, f := range searchFields {
+				ids, err := bySearch.Lookup(txn, f.code+":"+gram)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					if !seen[id] {
+						seen[id] = true
+						hits[id]++
+					}
+				}
+			}
+		}
+
+		// Only records containing every gram can contain the query
+		var ids []string
+		for id, n := range hits {
+			if n == len(grams) {
+				ids = append(ids, id)
+			}
+		}
+
+		var err error
+		candidates, err = mangaRecords.GetMany(txn, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rankResults(candidates, q, limit), nil
+}
+
+// rankResults scores records containing the normalized query
+// Each field containing the query adds its weight; a field equal to the
+// query or starting with it scores higher
+func rankResults(list []Manga, q string, limit int) []SearchResult {
+	var results []SearchResult
+	for _, mg := range list {
+		score := 0
+		for _, f := range searchFields {
+			text := NormalizeText(f.text(mg))
+			switch {
+			case text == q:
+				score += f.weight * 4
+			case strings.HasPrefix(text, q):
+				score += f.weight * 2
+			case strings.Contains(text, q):
+				score += f.weight
+			}
+		}
+		if score > 0 {
+			results = append(results, SearchResult{Manga: mg, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		a, b := results[i].Manga, results[j].Manga
+		if a.Series != b.Series {
+			return a.Series < b.Series
+		}
+		if a.Volume != b.Volume {
+			return a.Volume < b.Volume
+		}
+		return a.Title < b.Title
+	})
+
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	out := list[:0]
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package manga
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+// Subscription limits: anyone may subscribe, and every subscribed
+// series costs a Rakuten search on each release check
+const (
+	MaxSubscriptions    = 50  // Series a single user may subscribe to
+	MaxSubscribedSeries = 500 // Distinct series subscribed by all users
+)
+
+// Subscription is the set of series a Nostr user receives new release
+// messages for
+type Subscription struct {
+	Pubkey    string    `json:"pubkey"` // Hex public key
+	Series    []string  `json:"series"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReleaseState remembers the newest volume of a series that has been
+// checked and delivered, so each release is only sent once. The shared
+// library tracks subscribers; member libraries track their member.
+type ReleaseState struct {
+	Series    string    `json:"series"`
+	Latest    int       `json:"latest"`   // Newest volume found on Rakuten
+	Notified  int       `json:"notified"` // Newest volume sent or already known
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// subscriptionRecords stores subscriptions keyed by public key
+var subscriptionRecords = db.NewCollection[Subscription]("subscription:pubkey:", func(s Subscription) string {
+	return s.Pubkey
+})
+
+// bySubscribedSeries finds the subscribers of a series
+var bySubscribedSeries = subscriptionRecords.AddIndex("series", func(s Subscription) []string {
+	return s.Series
+})
+
+// releaseRecords stores release states keyed by series name
+var releaseRecords = db.NewCollection[ReleaseState]("release:series:", func(r ReleaseState) string {
+	return r.Series
+})
+
+// Subscribe adds a series to a user's subscription
+func (m *Manager) Subscribe(pubkey, series string) error {
+	if series == "" {
+		return fmt.Errorf("series is required")
+	}
+	return m.shared.Update(func(txn *db.Txn) error {
+		sub, err := subscriptionRecords.Get(txn, pubkey)
+		switch {
+		case db.IsNotFound(err):
+			sub = Subscription{Pubkey: pubkey, CreatedAt: time.Now()}
+		case err != nil:
+			return err
+		}
+
+		if slices.Contains(sub.Series, series) {
+			return nil
+		}
+		if len(sub.Series) >= MaxSubscriptions {
+			return fmt.Errorf("subscription limit of %d series reached", MaxSubscriptions)
+		}
+		if others, err := bySubscribedSeries.Lookup(txn, series); err != nil {
+			return err
+		} else if len(others) == 0 {
+			all, err := bySubscribedSeries.Values(txn)
+			if err != nil {
+				return err
+			}
+			if len(all) >= MaxSubscribedSeries {
+				return fmt.Errorf("no more series can be subscribed (limit %d)", MaxSubscribedSeries)
+			}
+		}
+		sub.Series = append(sub.Series, series)
+		sub.UpdatedAt = time.Now()
+		return subscriptionRecords.Put(txn, sub)
+	})
+}
+
+// Unsubscribe removes a series from a user's subscription
+func (m *Manager) Unsubscribe(pubkey, series string) error {
+	return m.shared.Update(func(txn *db.Txn) error {
+		sub, err := subscriptionRecords.Get(txn, pubkey)
+		if err != nil && !db.IsNotFound(err) {
+			return err
+		}
+		i := slices.Index(sub.Series, series)
+		if i < 0 {
+			return fmt.Errorf("not subscribed to %s", series)
+		}
+
+		sub.Series = slices.Delete(sub.Series, i, i+1)
+		if len(sub.Series) == 0 {
+			return subscriptionRecords.Delete(txn, pubkey)
+		}
+		sub.UpdatedAt = time.Now()
+		return subscriptionRecords.Put(txn, sub)
+	})
+}
+
+// ListSubscriptions returns the series a user is subscribed to
+func (m *Manager) ListSubscriptions(pubkey string) ([]string, error) {
+	var series []string
+	err := m.shared.View(func(txn *db.Txn) error {
+		sub, err := subscriptionRecords.Get(txn, pubkey)
+		if db.IsNotFound(err) {
+			return nil
+		}
+		series = sub.Series
+		return err
+	})
+	return series, err
+}
+
+// SubscribersOf returns the public keys subscribed to a series
+func (m *Manager) SubscribersOf(series string) ([]string, error) {
+	var pubkeys []string
+	err := m.shared.View(func(txn *db.Txn) error {
+		var err error
+		pubkeys, err = bySubscribedSeries.Lookup(txn, series)
+		return err
+	})
+	return pubkeys, err
+}
+
+// SubscribedSeries returns every series with at least one subscriber
+func (m *Manager) SubscribedSeries() ([]string, error) {
+	var series []string
+	err := m.shared.View(func(txn *db.Txn) error {
+		var err error
+		series, err = bySubscribedSeries.Values(txn)
+		return err
+	})
+	return series, err
+}
+
+// MarkReleaseNotified records that subscribers have been sent a volume
+func (m *Manager) MarkReleaseNotified(series string, volume int) error {
+	return m.db.Update(func(txn *db.Txn) error {
+		state, err := releaseRecords.Get(txn, series)
+		if err != nil && !db.IsNotFound(err) {
+			return err
+		}
+		state.Series = series
+		state.Notified = max(state.Notified, volume)
+		return releaseRecords.Put(txn, state)
+	})
+}
+
+// getReleaseState returns the release state of a series
+// found is false when the series has never been checked
+func (m *Manager) getReleaseState(series string) (state ReleaseState, found bool, err error) {
+	err = m.db.View(func(txn *db.Txn) error {
+		var err error
+		state, err = releaseRecords.Get(txn, series)
+		return err
+	})
+	if db.IsNotFound(err) {
+		return ReleaseState{Series: series}, false, nil
+	}
+	return state, err == nil, err
+}
+
+// trackRelease records the newest volume of a series found on Rakuten and
+// reports whether it has yet to be sent. seen is the newest volume the
+// recipients already know about, e.g. the newest one they own.
+func (m *Manager) trackRelease(series string, latest, seen int) (bool, error) {
+	var pending bool
+	err := m.db.Update(func(txn *db.Txn) error {
+		state, err := releaseRecords.Get(txn, series)
+		if err != nil && !db.IsNotFound(err) {
+			return err
+		}
+		state.Series = series
+		state.Latest = latest
+		state.Notified = max(state.Notified, seen)
+		state.CheckedAt = time.Now()
+		pending = latest > state.Notified
+		return releaseRecords.Put(txn, state)
+	})
+	return pending, err
+}
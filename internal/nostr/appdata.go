@@ -0,0 +1,133 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// AppData is a private application record: a parameterized replaceable
+// event (NIP-78, kind 30078) whose content is NIP-44 encrypted to
+// ourselves, so only holders of the secret key can read it
+type AppData struct {
+	ID        string // d tag
+	Content   string // Decrypted content
+	CreatedAt time.Time
+}
+
+// BlindID derives a stable identifier for name, for use as an AppData ID
+// It depends only on the public key, so a local key and a remote signer
+// for the same account agree on it.
+func (c *Client) BlindID(name string) string {
+	sum := sha256.Sum256([]byte(c.pubkey + ":" + name))
+	return hex.EncodeToString(sum[:16])
+}
+
+// PublishAppData encrypts content to ourselves and publishes it under id,
+// dated createdAt. Relays keep only the newest event for each id.
+func (c *Client) PublishAppData(id, content string, createdAt time.Time) error {
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, 15*time.Second)
+	defer cancel()
+	encrypted, err := c.signer.Encrypt(ctx, content, c.pubkey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return c.publishEvent(nostr.Event{
+		Kind:      nostr.KindApplicationSpecificData,
+		Content:   encrypted,
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Tags:      nostr.Tags{{"d", id}},
+	})
+}
+
+// appDataChunk is the number of ids asked for in one request, well
+// below the number of events relays return per answer
+const appDataChunk = 50
+
+// FetchAppData returns the newest version of each of our AppData records
+// with the given ids created after since. Records that cannot be
+// decrypted are skipped.
+//
+// Relays cap the number of events in an answer, and events published
+// together share a date, so records are asked for by id: each relay is
+// queried a chunk of ids at a time, again for the ids it left out, until
+// it has nothing more.
+func (c *Client) FetchAppData(ctx context.Context, ids []string, since time.Time) ([]AppData, error) {
+	if c.signer == nil {
+		return nil, fmt.Errorf("secret key not configured")
+	}
+	if c.pool == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	latest := make(map[string]*nostr.Event)
+	for _, url := range c.relays {
+		for start := 0; start < len(ids); start += appDataChunk {
+			chunk := ids[start:min(start+appDataChunk, len(ids))]
+			if err := c.fetchAppDataFrom(ctx, url, chunk, since, latest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	records := make([]AppData, 0, len(latest))
+	for id, ev := range latest {
+		content, err := c.signer.Decrypt(ctx, ev.Content, c.pubkey)
+		if err != nil {
+			continue
+		}
+		records = append(records, AppData{ID: id, Content: content, CreatedAt: ev.CreatedAt.Time()})
+	}
+	return records, nil
+}
+
+// fetchAppDataFrom asks one relay for the records with ids, keeping the
+// newest version of each in latest
+func (c *Client) fetchAppDataFrom(ctx context.Context, url string, ids []string, since time.Time, latest map[string]*nostr.Event) error {
+	filter := nostr.Filter{
+		Kinds:   []int{nostr.KindApplicationSpecificData},
+		Authors: []string{c.pubkey},
+	}
+	if !since.IsZero() {
+		ts := nostr.Timestamp(since.Unix())
+		filter.Since = &ts
+	}
+
+	remaining := ids
+	for len(remaining) > 0 {
+		page := filter
+		page.Tags = nostr.TagMap{"d": remaining}
+
+		found := make(map[string]bool)
+		// FetchMany normalizes the URL slice in place
+		for ie := range c.pool.FetchMany(ctx, []string{url}, page) {
+			id := ie.Tags.GetD()
+			if !slices.Contains(remaining, id) {
+				continue
+			}
+			found[id] = true
+
+			// Like relays, break ties in favour of the lowest event ID
+			prev, ok := latest[id]
+			if !ok || ie.CreatedAt > prev.CreatedAt || (ie.CreatedAt == prev.CreatedAt && ie.ID < prev.ID) {
+				latest[id] = ie.Event
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+		if len(found) == 0 {
+			return nil
+		}
+		remaining = slices.DeleteFunc(slices.Clone(remaining), func(id string) bool { return found[id] })
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFetchAppDataFromCappedRelay(t *testing.T) {
+	relay := newTestRelay(t, 7)
+	c := newTestClient(t, Config{Relays: []string{relay.URL}})
+
+	// Published together, as a sync does, so every record shares a date
+	at := time.Now()
+	var ids []string
+	for i := range 120 {
+		id := c.BlindID(fmt.Sprintf("test:%d", i))
+		ids = append(ids, id)
+		if err := c.PublishAppData(id, fmt.Sprintf("record %d", i), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	records, err := c.FetchAppData(ctx, ids, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(ids) {
+		t.Fatalf("fetched %d records from a relay answering 7 at a time, want %d", len(records), len(ids))
+	}
+	got := make(map[string]string)
+	for _, r := range records {
+		got[r.ID] = r.Content
+	}
+	for i, id := range ids {
+		if got[id] != fmt.Sprintf("record %d", i) {
+			t.Errorf("record %d = %q", i, got[id])
+		}
+	}
+}
@@ -3,6 +3,10 @@ package nostr
 import (
 	"context"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nbd-wtf/go-nostr"
@@ -11,120 +15,213 @@ import (
 
 // Client represents a Nostr client
 type Client struct {
-	secretKey string
-	relays    []string
-	pool      *nostr.SimplePool
-	relayPool map[string]*nostr.Relay
-	cancel    context.CancelFunc
+	signer Signer // Signs and encrypts; nil for a read-only client
+	pubkey string // Hex public key of the signer
+	relays []string
+	pool   *nostr.SimplePool // Subscriptions and NIP-17 relay lookups
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	conns      map[string]*relayConn   // Publishing connections by relay URL
+	relayLists map[string]cachedRelays // Other users' inbox relays by public key
+
+	outbox   *Outbox
+	quorum   int
+	maxAge   time.Duration
+	inFlight map[string]bool // Event IDs being attempted, guarded by mu
+
+	mediaServer   string
+	mediaProtocol string
 }
 
 // Config holds Nostr client configuration
 type Config struct {
 	SecretKey string // nsec or hex
+	Signer    Signer // Used instead of SecretKey, such as a NIP-46 bunker
 	Relays    []string
+
+	Outbox       *Outbox       // Queues events until published; nil publishes once
+	Quorum       int           // Relays that must accept an event (default 1)
+	OutboxMaxAge time.Duration // Give up on queued events after this (default 24h)
+
+	MediaServer   string // Blossom server or NIP-96 upload URL for images
+	MediaProtocol string // blossom (default) or nip96
 }
 
 // NewClient creates a new Nostr client
 func NewClient(cfg Config) (*Client, error) {
-	// Normalize secret key if in nsec format
-	secretKey := cfg.SecretKey
-	if len(secretKey) > 0 && secretKey[:4] == "nsec" {
-		_, hex, err := nip19.Decode(secretKey)
+	signer := cfg.Signer
+	if signer == nil && cfg.SecretKey != "" {
+		local, err := NewLocalSigner(cfg.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		signer = local
+	}
+
+	var pubkey string
+	if signer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		pk, err := signer.GetPublicKey(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode nsec: %w", err)
+			return nil, fmt.Errorf("failed to get public key: %w", err)
 		}
-		secretKey = hex.(string)
+		pubkey = pk
 	}
 
+	quorum := cfg.Quorum
+	if quorum <= 0 {
+		quorum = 1
+	}
+	maxAge := cfg.OutboxMaxAge
+	if maxAge <= 0 {
+		maxAge = 24 * time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Client{
-		secretKey: secretKey,
-		relays:    cfg.Relays,
-		relayPool: make(map[string]*nostr.Relay),
+		signer:     signer,
+		pubkey:     pubkey,
+		relays:     cfg.Relays,
+		ctx:        ctx,
+		cancel:     cancel,
+		conns:      make(map[string]*relayConn),
+		relayLists: make(map[string]cachedRelays),
+		outbox:     cfg.Outbox,
+		inFlight:   make(map[string]bool),
+		quorum:     quorum,
+		maxAge:     maxAge,
+
+		mediaServer:   cfg.MediaServer,
+		mediaProtocol: cfg.MediaProtocol,
 	}, nil
 }
 
-// Connect connects to configured relays
+// Connect connects to configured relays and starts publishing queued
+// events. Relays that are down are retried in the background.
 func (c *Client) Connect() error {
-	ctx, cancel := context.WithCancel(context.Background())
-	c.cancel = cancel
-
-	c.pool = nostr.NewSimplePool(ctx)
+	c.pool = nostr.NewSimplePool(c.ctx)
 
+	connected := 0
 	for _, url := range c.relays {
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Printf("Warning: failed to connect to %s: %v\n", url, err)
+		if _, err := c.conn(url).ensure(); err != nil {
+			log.Printf("Warning: failed to connect to %s: %v", url, err)
 			continue
 		}
-		c.relayPool[url] = relay
-		fmt.Printf("Connected to relay: %s\n", url)
+		connected++
+		log.Printf("Connected to relay: %s", url)
 	}
 
-	if len(c.relayPool) == 0 {
+	if connected == 0 {
 		return fmt.Errorf("failed to connect to any relays")
 	}
 
+	c.retryPending(true)
+	go c.maintain(c.ctx)
 	return nil
 }
 
 // Disconnect disconnects from all relays
+// Events still in the outbox are published when the client next starts.
 func (c *Client) Disconnect() {
-	if c.cancel != nil {
-		c.cancel()
-	}
-	for url, relay := range c.relayPool {
-		relay.Close()
-		fmt.Printf("Disconnected from: %s\n", url)
+	c.cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for url, rc := range c.conns {
+		rc.close()
+		log.Printf("Disconnected from: %s", url)
 	}
-	c.relayPool = make(map[string]*nostr.Relay)
 }
 
 // Publish publishes a text note event to relays
 func (c *Client) Publish(content string) error {
-	if c.secretKey == "" {
+	return c.publishEvent(nostr.Event{
+		Kind:      1, // Text note
+		Content:   content,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+	})
+}
+
+// SendDirectMessage sends an encrypted direct message (NIP-04) to a user
+// on our relays and the inbox relays from their NIP-65 list. The
+// recipient may be an npub or a hex public key.
+func (c *Client) SendDirectMessage(recipient, content string) error {
+	if c.signer == nil {
 		return fmt.Errorf("secret key not configured")
 	}
 
-	// Create event
+	pubkey, err := DecodePublicKey(recipient)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 15*time.Second)
+	defer cancel()
+	encrypted, err := c.signer.EncryptNIP04(ctx, content, pubkey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message: %w", err)
+	}
+
 	ev := nostr.Event{
-		Kind:      1, // Text note
-		Content:   content,
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Content:   encrypted,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      nostr.Tags{{"p", pubkey}},
+	}
+	if err := c.signer.SignEvent(ctx, &ev); err != nil {
+		return fmt.Errorf("failed to sign event: %w", err)
+	}
+
+	relays := c.relays
+	for _, url := range c.inboxRelays(ctx, pubkey) {
+		if !slices.ContainsFunc(relays, func(r string) bool { return nostr.NormalizeURL(r) == url }) {
+			relays = append(slices.Clip(relays), url)
+		}
+	}
+	return c.send(ev, relays)
+}
+
+// publishEvent signs an event and publishes it to our relays
+func (c *Client) publishEvent(ev nostr.Event) error {
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
 	}
 
 	// Sign event
-	if err := ev.Sign(c.secretKey); err != nil {
+	ctx, cancel := context.WithTimeout(c.ctx, 30*time.Second)
+	defer cancel()
+	if err := c.signer.SignEvent(ctx, &ev); err != nil {
 		return fmt.Errorf("failed to sign event: %w", err)
 	}
 
-	// Publish to all connected relays
-	ctx := context.Background()
-	var lastErr error
-	for url, relay := range c.relayPool {
-		if err := relay.Publish(ctx, ev); err != nil {
-			fmt.Printf("Failed to publish to %s: %v\n", url, err)
-			lastErr = err
-			continue
+	return c.send(ev, c.relays)
+}
+
+// DecodePublicKey converts an npub or hex public key to hex
+func DecodePublicKey(key string) (string, error) {
+	if strings.HasPrefix(key, "npub") {
+		_, v, err := nip19.Decode(key)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode npub: %w", err)
 		}
-		fmt.Printf("Published to %s\n", url)
+		return v.(string), nil
 	}
-
-	return lastErr
+	if !nostr.IsValidPublicKey(key) {
+		return "", fmt.Errorf("invalid public key %q", key)
+	}
+	return key, nil
 }
 
-// GetPublicKey returns the public key (npub) from the secret key
+// GetPublicKey returns the public key (npub) of the signer
 func (c *Client) GetPublicKey() (string, error) {
-	if c.secretKey == "" {
+	if c.signer == nil {
 		return "", fmt.Errorf("secret key not configured")
 	}
 
-	// Create a dummy event to extract public key
-	ev := &nostr.Event{}
-	if err := ev.Sign(c.secretKey); err != nil {
-		return "", fmt.Errorf("failed to get public key: %w", err)
-	}
-
-	npub, err := nip19.EncodePublicKey(ev.PubKey)
+	npub, err := nip19.EncodePublicKey(c.pubkey)
 	if err != nil {
 		return "", fmt.Errorf("failed to encode npub: %w", err)
 	}
@@ -0,0 +1,145 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip10"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Message is a message addressed to the bot
+type Message struct {
+	ID      string
+	From    string // Hex public key of the sender
+	Content string // Plain text, decrypted for direct messages
+	Direct  bool   // Encrypted direct message rather than a public mention
+	Private bool   // NIP-17 private message rather than NIP-04
+}
+
+// mentionPattern matches nostr: references and @names at the start of a mention
+var mentionPattern = regexp.MustCompile(`^(\s*(nostr:n(pub|profile)1[0-9a-z]+|@\S+))+`)
+
+// profileRefPattern matches a profile reference anywhere in a note:
+// NIP-27 nostr:npub/nprofile, @npub, or a legacy NIP-08 #[index]
+var profileRefPattern = regexp.MustCompile(`(?:nostr:|@)(n(?:pub|profile)1[0-9a-z]+)|#\[(\d+)\]`)
+
+// Listen subscribes to mentions and direct messages (NIP-04 and NIP-17)
+// addressed to the bot and calls handle for each one until ctx is
+// cancelled. Only messages created after Listen starts are delivered.
+// handle may be called from several goroutines.
+func (c *Client) Listen(ctx context.Context, handle func(Message)) error {
+	if c.pool == nil {
+		return fmt.Errorf("not connected")
+	}
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+	pubkey := c.pubkey
+
+	start := time.Now()
+	go c.listenPrivate(ctx, pubkey, start, handle)
+
+	since := nostr.Timestamp(start.Unix())
+	filter := nostr.Filter{
+		Kinds: []int{nostr.KindTextNote, nostr.KindEncryptedDirectMessage},
+		Tags:  nostr.TagMap{"p": []string{pubkey}},
+		Since: &since,
+	}
+
+	// SubscribeMany normalizes the URL slice in place
+	urls := append([]string(nil), c.relays...)
+	for ev := range c.pool.SubscribeMany(ctx, urls, filter) {
+		if ev.PubKey == pubkey {
+			continue // Our own replies
+		}
+
+		msg := Message{ID: ev.ID, From: ev.PubKey}
+		switch ev.Kind {
+		case nostr.KindEncryptedDirectMessage:
+			content, err := c.signer.DecryptNIP04(ctx, ev.Content, ev.PubKey)
+			if err != nil {
+				continue
+			}
+			msg.Content = content
+			msg.Direct = true
+		default:
+			if !addressedTo(ev.Event, pubkey) {
+				continue // Someone else's thread we are only tagged in
+			}
+			msg.Content = mentionPattern.ReplaceAllString(ev.Content, "")
+		}
+		msg.Content = strings.TrimSpace(msg.Content)
+
+		handle(msg)
+	}
+	return ctx.Err()
+}
+
+// addressedTo reports whether a note that p-tags pubkey is meant for it
+// Replies carry the p tags of everyone in the thread, so a reply counts
+// only if its text mentions pubkey or it answers one of pubkey's notes.
+func addressedTo(ev *nostr.Event, pubkey string) bool {
+	if mentions(ev, pubkey) {
+		return true
+	}
+	parent := nip10.GetImmediateParent(ev.Tags)
+	if parent == nil {
+		return true // A new note tagging us
+	}
+	ep, ok := parent.(nostr.EventPointer)
+	return ok && ep.Author == pubkey
+}
+
+// mentions reports whether the text of a note refers to pubkey
+func mentions(ev *nostr.Event, pubkey string) bool {
+	for _, m := range profileRefPattern.FindAllStringSubmatch(ev.Content, -1) {
+		if m[1] != "" {
+			_, v, err := nip19.Decode(m[1])
+			if err != nil {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				if v == pubkey {
+					return true
+				}
+			case nostr.ProfilePointer:
+				if v.PublicKey == pubkey {
+					return true
+				}
+			}
+			continue
+		}
+		i, err := strconv.Atoi(m[2])
+		if err == nil && i < len(ev.Tags) && len(ev.Tags[i]) >= 2 && ev.Tags[i][0] == "p" && ev.Tags[i][1] == pubkey {
+			return true
+		}
+	}
+	return false
+}
+
+// Reply answers a message the way it arrived: by the same kind of
+// direct message, or with a public reply to the mention
+func (c *Client) Reply(msg Message, content string) error {
+	if msg.Private {
+		return c.SendPrivateMessage(msg.From, content)
+	}
+	if msg.Direct {
+		return c.SendDirectMessage(msg.From, content)
+	}
+	return c.publishEvent(nostr.Event{
+		Kind:      1, // Text note
+		Content:   content,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags: nostr.Tags{
+			{"e", msg.ID, "", "root"},
+			{"p", msg.From},
+		},
+	})
+}
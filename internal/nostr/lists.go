@@ -0,0 +1,73 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// List is a NIP-51 bookmark set (kind 30003) of books
+type List struct {
+	ID          string // d tag, unique per list
+	Title       string
+	Description string
+	Owner       string // Hex public key the list is kept for, if not the bot's own
+	Items       []ListItem
+}
+
+// ListItem is a book, referenced by ISBN as a NIP-73 external content ID
+type ListItem struct {
+	ISBN string
+	URL  string // Optional hint where the book can be found
+}
+
+// PublishList publishes a list if it differs from the version on our
+// relays. Each list replaces the previous one with the same ID.
+func (c *Client) PublishList(l List) error {
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+
+	tags := nostr.Tags{{"d", l.ID}, {"title", l.Title}}
+	if l.Description != "" {
+		tags = append(tags, nostr.Tag{"description", l.Description})
+	}
+	if l.Owner != "" {
+		tags = append(tags, nostr.Tag{"p", l.Owner})
+	}
+	tags = append(tags, nostr.Tag{"k", "isbn"})
+	for _, item := range l.Items {
+		tag := nostr.Tag{"i", "isbn:" + strings.ReplaceAll(item.ISBN, "-", "")}
+		if item.URL != "" {
+			tag = append(tag, item.URL)
+		}
+		tags = append(tags, tag)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 15*time.Second)
+	defer cancel()
+	// The list replaces the published one whole, so if it cannot be
+	// fetched it is simply published again
+	current, _ := c.fetchLatest(ctx, c.relays, nostr.Filter{
+		Kinds:   []int{nostr.KindBookmarkSets},
+		Authors: []string{c.pubkey},
+		Tags:    nostr.TagMap{"d": []string{l.ID}},
+	})
+	ev := nostr.Event{
+		Kind:      nostr.KindBookmarkSets,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	}
+	if current != nil {
+		if slices.EqualFunc(current.Tags, tags, slices.Equal) {
+			return nil
+		}
+		// Relays keep the newer event, so never reuse its second
+		ev.CreatedAt = max(ev.CreatedAt, current.CreatedAt+1)
+	}
+	return c.publishEvent(ev)
+}
@@ -0,0 +1,163 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip94"
+	"github.com/nbd-wtf/go-nostr/nip96"
+	"github.com/nbd-wtf/go-nostr/nipb0/blossom"
+)
+
+// Media server protocols
+const (
+	MediaBlossom = "blossom"
+	MediaNIP96   = "nip96"
+)
+
+// Image describes an image attached to a note
+type Image struct {
+	URL      string
+	MimeType string
+	SHA256   string
+	Size     int64
+	Width    int
+	Height   int
+	Blurhash string
+	Alt      string
+}
+
+// IMetaTag returns the NIP-92 imeta tag for the image
+// Field names follow the NIP-94 file metadata tags
+func (img Image) IMetaTag() nostr.Tag {
+	fm := nip94.FileMetadata{
+		URL:      img.URL,
+		M:        img.MimeType,
+		X:        img.SHA256,
+		Blurhash: img.Blurhash,
+	}
+	if img.Size > 0 {
+		fm.Size = strconv.FormatInt(img.Size, 10)
+	}
+	if img.Width > 0 && img.Height > 0 {
+		fm.Dim = fmt.Sprintf("%dx%d", img.Width, img.Height)
+	}
+
+	tag := nostr.Tag{"imeta"}
+	for _, t := range fm.ToTags() {
+		tag = append(tag, t[0]+" "+t[1])
+	}
+	if img.Alt != "" {
+		tag = append(tag, "alt "+img.Alt)
+	}
+	return tag
+}
+
+// PublishWithImages publishes a text note with images attached
+// Image URLs are appended to the content, as NIP-92 requires, and
+// described with imeta tags so clients can render them before loading
+func (c *Client) PublishWithImages(content string, images []Image) error {
+	var tags nostr.Tags
+	for _, img := range images {
+		if !strings.Contains(content, img.URL) {
+			content += "\n" + img.URL
+		}
+		tags = append(tags, img.IMetaTag())
+	}
+
+	return c.publishEvent(nostr.Event{
+		Kind:      1, // Text note
+		Content:   content,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	})
+}
+
+// HasMediaServer reports whether a media server is configured
+func (c *Client) HasMediaServer() bool {
+	return c.mediaServer != ""
+}
+
+// UploadImage uploads an image file to the configured media server
+// and returns img with its URL set. Metadata reported by the server
+// replaces the local values, since NIP-96 servers may re-encode images.
+func (c *Client) UploadImage(path string, img Image) (Image, error) {
+	if c.mediaServer == "" {
+		return img, fmt.Errorf("media server not configured")
+	}
+	if c.signer == nil {
+		return img, fmt.Errorf("secret key not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch c.mediaProtocol {
+	case MediaBlossom, "":
+		bd, err := blossom.NewClient(c.mediaServer, c.signer).UploadFile(ctx, path)
+		if err != nil {
+			return img, err
+		}
+		img.URL = bd.URL
+		if bd.SHA256 != "" {
+			img.SHA256 = bd.SHA256
+		}
+		if bd.Size > 0 {
+			img.Size = int64(bd.Size)
+		}
+		return img, nil
+
+	case MediaNIP96:
+		// go-nostr signs NIP-96 uploads with the raw key only
+		local, ok := c.signer.(*LocalSigner)
+		if !ok {
+			return img, fmt.Errorf("NIP-96 uploads need a local secret key; use a Blossom server with a remote signer")
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return img, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+
+		resp, err := nip96.Upload(ctx, nip96.UploadRequest{
+			Host:        c.mediaServer,
+			SK:          local.sk,
+			SignPayload: true,
+			File:        f,
+			Filename:    filepath.Base(path),
+			ContentType: img.MimeType,
+			Alt:         img.Alt,
+		})
+		if err != nil {
+			return img, fmt.Errorf("failed to upload %s: %w", path, err)
+		}
+
+		fm := nip94.ParseFileMetadata(nostr.Event{Tags: resp.Nip94Event.Tags})
+		if fm.URL == "" {
+			return img, fmt.Errorf("media server returned no URL: %s", resp.Message)
+		}
+		img.URL = fm.URL
+		if fm.X != "" {
+			img.SHA256 = fm.X
+		}
+		if fm.Dim != "" {
+			fmt.Sscanf(fm.Dim, "%dx%d", &img.Width, &img.Height)
+		}
+		if size, err := strconv.ParseInt(fm.Size, 10, 64); err == nil {
+			img.Size = size
+		}
+		if fm.Blurhash != "" {
+			img.Blurhash = fm.Blurhash
+		}
+		return img, nil
+	}
+
+	return img, fmt.Errorf("unknown media protocol %q: use blossom or nip96", c.mediaProtocol)
+}
@@ -0,0 +1,265 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Retry schedule for events that have not reached their quorum
+const (
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// OutboxEntry is a signed event waiting for enough relays to accept it
+type OutboxEntry struct {
+	Event       nostr.Event       `json:"event"`
+	Relays      []string          `json:"relays"`   // Relays to publish to
+	Accepted    []string          `json:"accepted"` // Relays that answered OK
+	Results     map[string]string `json:"results"`  // Last error per relay that has not accepted
+	Quorum      int               `json:"quorum"`   // Accepting relays needed to finish
+	Attempts    int               `json:"attempts"`
+	CreatedAt   time.Time         `json:"created_at"`
+	NextAttempt time.Time         `json:"next_attempt"`
+}
+
+// outboxRecords stores outbox entries keyed by event ID
+var outboxRecords = db.NewCollection[OutboxEntry]("outbox:event:", func(e OutboxEntry) string {
+	return e.Event.ID
+})
+
+// Outbox persists signed events until they are published, so events
+// survive relay outages and restarts
+type Outbox struct {
+	db *db.DB
+}
+
+// NewOutbox creates an outbox stored in the database
+func NewOutbox(database *db.DB) *Outbox {
+	return &Outbox{db: database}
+}
+
+// Pending returns the queued events, oldest first
+func (o *Outbox) Pending() ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := o.db.View(func(txn *db.Txn) error {
+		page, err := outboxRecords.Scan(txn, db.Query[OutboxEntry]{
+			Less: func(a, b OutboxEntry) bool { return a.CreatedAt.Before(b.CreatedAt) },
+		})
+		entries = page.Items
+		return err
+	})
+	return entries, err
+}
+
+func (o *Outbox) put(e OutboxEntry) error {
+	return o.db.Update(func(txn *db.Txn) error {
+		return outboxRecords.Put(txn, e)
+	})
+}
+
+func (o *Outbox) get(id string) (OutboxEntry, error) {
+	var e OutboxEntry
+	err := o.db.View(func(txn *db.Txn) error {
+		var err error
+		e, err = outboxRecords.Get(txn, id)
+		return err
+	})
+	return e, err
+}
+
+func (o *Outbox) delete(id string) error {
+	return o.db.Update(func(txn *db.Txn) error {
+		return outboxRecords.Delete(txn, id)
+	})
+}
+
+// send publishes a signed event to relays. With an outbox the event is
+// stored first and retried in the background until the quorum accepts
+// it; without one, failing to reach the quorum is an error.
+func (c *Client) send(ev nostr.Event, relays []string) error {
+	if len(relays) == 0 {
+		return fmt.Errorf("no relays to publish to")
+	}
+
+	now := time.Now()
+	entry := OutboxEntry{
+		Event:       ev,
+		Relays:      relays,
+		Results:     make(map[string]string),
+		Quorum:      min(c.quorum, len(relays)),
+		CreatedAt:   now,
+		NextAttempt: now.Add(minRetryDelay), // Should this attempt be cut short
+	}
+	if !c.claim(ev.ID) {
+		return nil // Already being published
+	}
+	defer c.release(ev.ID)
+	if c.outbox != nil {
+		if err := c.outbox.put(entry); err != nil {
+			return fmt.Errorf("failed to queue event: %w", err)
+		}
+	}
+	return c.attempt(&entry)
+}
+
+// claim marks an event as being attempted, so a retry that comes due
+// while an attempt is still waiting for relays does not send it again
+// It returns false if an attempt is already in flight.
+func (c *Client) claim(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight[id] {
+		return false
+	}
+	c.inFlight[id] = true
+	return true
+}
+
+func (c *Client) release(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, id)
+}
+
+// attempt publishes an entry to the relays that have not accepted it yet
+// The caller must hold the entry's claim.
+func (c *Client) attempt(entry *OutboxEntry) error {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, url := range entry.Relays {
+		if slices.Contains(entry.Accepted, url) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rc, temporary := c.publisher(url)
+			if temporary {
+				defer rc.close()
+			}
+			err := rc.publish(c.ctx, entry.Event)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				entry.Results[url] = err.Error()
+				return
+			}
+			entry.Accepted = append(entry.Accepted, url)
+			delete(entry.Results, url)
+		}()
+	}
+	wg.Wait()
+	entry.Attempts++
+
+	if len(entry.Accepted) >= entry.Quorum {
+		log.Printf("Published %s to %d/%d relays", entry.Event.ID, len(entry.Accepted), len(entry.Relays))
+		if c.outbox != nil {
+			if err := c.outbox.delete(entry.Event.ID); err != nil {
+				return fmt.Errorf("failed to remove published event: %w", err)
+			}
+		}
+		return nil
+	}
+
+	failed := fmt.Errorf("event %s accepted by %d of %d relays needed: %s",
+		entry.Event.ID, len(entry.Accepted), entry.Quorum, entry.failures())
+	if c.outbox == nil {
+		return failed
+	}
+	if time.Since(entry.CreatedAt) > c.maxAge {
+		if err := c.outbox.delete(entry.Event.ID); err != nil {
+			return fmt.Errorf("failed to remove expired event: %w", err)
+		}
+		return fmt.Errorf("giving up after %d attempts: %w", entry.Attempts, failed)
+	}
+
+	delay := min(minRetryDelay<<min(entry.Attempts-1, 10), maxRetryDelay)
+	entry.NextAttempt = time.Now().Add(delay)
+	if err := c.outbox.put(*entry); err != nil {
+		return fmt.Errorf("failed to queue event: %w", err)
+	}
+	log.Printf("Queued for retry in %s: %v", delay, failed)
+	return nil
+}
+
+// failures describes why relays have not accepted the entry
+func (e OutboxEntry) failures() string {
+	parts := make([]string, 0, len(e.Results))
+	for _, url := range e.Relays {
+		if msg, ok := e.Results[url]; ok {
+			parts = append(parts, url+": "+msg)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// retryPending publishes queued events that are due, or all of them
+func (c *Client) retryPending(all bool) {
+	if c.outbox == nil {
+		return
+	}
+	entries, err := c.outbox.Pending()
+	if err != nil {
+		log.Printf("Failed to read outbox: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if !all && time.Now().Before(entry.NextAttempt) {
+			continue
+		}
+		if err := c.retry(entry.Event.ID); err != nil {
+			log.Printf("Outbox: %v", err)
+		}
+	}
+}
+
+// retry attempts a queued event unless an attempt is already in flight
+// The entry is read again once claimed, since an attempt that finished
+// in the meantime may have published or rescheduled it.
+func (c *Client) retry(id string) error {
+	if !c.claim(id) {
+		return nil
+	}
+	defer c.release(id)
+
+	entry, err := c.outbox.get(id)
+	if db.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read queued event: %w", err)
+	}
+	if entry.Results == nil {
+		entry.Results = make(map[string]string)
+	}
+	return c.attempt(&entry)
+}
+
+// maintain reconnects dropped relays and retries queued events until
+// the client is disconnected
+func (c *Client) maintain(ctx context.Context) {
+	ticker := time.NewTicker(minRetryDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, url := range c.relays {
+				c.conn(url).ensure()
+			}
+			c.retryPending(false)
+		}
+	}
+}
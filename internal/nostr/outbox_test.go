@@ -0,0 +1,116 @@
+package nostr
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func testEvent(t *testing.T, sk string) nostr.Event {
+	t.Helper()
+	ev := nostr.Event{Kind: 1, Content: "test", CreatedAt: nostr.Now()}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestSendClosesInboxConnections(t *testing.T) {
+	ours, inbox := newTestRelay(t, 0), newTestRelay(t, 0)
+	sk := nostr.GeneratePrivateKey()
+	c := newTestClient(t, Config{SecretKey: sk, Relays: []string{ours.URL}})
+
+	ev := testEvent(t, sk)
+	if err := c.send(ev, []string{ours.URL, inbox.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox.mu.Lock()
+	received := inbox.received[ev.ID]
+	inbox.mu.Unlock()
+	if received != 1 {
+		t.Fatalf("inbox relay received the event %d times, want 1", received)
+	}
+
+	// The server notices the close asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		inbox.mu.Lock()
+		open := inbox.conns
+		inbox.mu.Unlock()
+		if open == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connection(s) to the inbox relay left open", open)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ours.mu.Lock()
+	defer ours.mu.Unlock()
+	if ours.conns != 1 {
+		t.Errorf("%d connection(s) to the configured relay, want 1", ours.conns)
+	}
+}
+
+func TestRetrySkipsEventInFlight(t *testing.T) {
+	d, err := db.NewDB(db.Config{Path: filepath.Join(t.TempDir(), "komikan.db"), SkipMigrations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	relay := newTestRelay(t, 0)
+	sk := nostr.GeneratePrivateKey()
+	outbox := NewOutbox(d)
+	c := newTestClient(t, Config{SecretKey: sk, Relays: []string{relay.URL}, Outbox: outbox})
+
+	ev := testEvent(t, sk)
+	err = outbox.put(OutboxEntry{
+		Event:     ev,
+		Relays:    []string{relay.URL},
+		Quorum:    1,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry while another attempt is running leaves the event alone
+	if !c.claim(ev.ID) {
+		t.Fatal("claim of idle event failed")
+	}
+	c.retryPending(true)
+	relay.mu.Lock()
+	received := relay.received[ev.ID]
+	relay.mu.Unlock()
+	if received != 0 {
+		t.Fatalf("event sent %d times during a running attempt", received)
+	}
+
+	// The running attempt publishes it, so a later retry has nothing to do
+	entry, err := outbox.get(ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Results = make(map[string]string)
+	if err := c.attempt(&entry); err != nil {
+		t.Fatal(err)
+	}
+	c.release(ev.ID)
+	c.retryPending(true)
+
+	relay.mu.Lock()
+	received = relay.received[ev.ID]
+	relay.mu.Unlock()
+	if received != 1 {
+		t.Errorf("event sent %d times, want 1", received)
+	}
+	if pending, err := outbox.Pending(); err != nil || len(pending) != 0 {
+		t.Errorf("outbox after publishing = %v, %v; want empty", pending, err)
+	}
+}
@@ -0,0 +1,93 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+)
+
+// giftWrapWindow is how far back gift wraps may be dated (NIP-59
+// randomizes their timestamps up to two days into the past)
+const giftWrapWindow = 2 * 24 * time.Hour
+
+// SendPrivateMessage sends a NIP-17 private direct message, gift-wrapped
+// with NIP-44 encryption. It is delivered to the recipient's DM relays
+// (kind 10050), falling back to the inbox relays of their NIP-65 list
+// and then to our relays. Both copies go through the outbox like other
+// events.
+func (c *Client) SendPrivateMessage(recipient, content string) error {
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+
+	pubkey, err := DecodePublicKey(recipient)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	theirRelays := nip17.GetDMRelays(ctx, pubkey, c.pool, c.relays)
+	if len(theirRelays) == 0 {
+		theirRelays = c.inboxRelays(ctx, pubkey)
+	}
+	if len(theirRelays) == 0 {
+		theirRelays = c.relays
+	}
+
+	toUs, toThem, err := nip17.PrepareMessage(ctx, content, nostr.Tags{}, c.signer, pubkey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to prepare message: %w", err)
+	}
+
+	// Our copy lets other clients of ours show the conversation
+	if err := c.send(toThem, theirRelays); err != nil {
+		return err
+	}
+	if err := c.send(toUs, c.relays); err != nil {
+		log.Printf("Warning: failed to store our copy of the message: %v", err)
+	}
+	return nil
+}
+
+// PublishDMRelays announces our relays as the place to send NIP-17
+// messages to us (kind 10050)
+func (c *Client) PublishDMRelays() error {
+	tags := make(nostr.Tags, 0, len(c.relays))
+	for _, url := range c.relays {
+		tags = append(tags, nostr.Tag{"relay", url})
+	}
+	return c.publishEvent(nostr.Event{
+		Kind:      nostr.KindDMRelayList,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	})
+}
+
+// listenPrivate delivers NIP-17 messages received after start
+func (c *Client) listenPrivate(ctx context.Context, pubkey string, start time.Time, handle func(Message)) {
+	since := nostr.Timestamp(start.Add(-giftWrapWindow).Unix())
+	urls := append([]string(nil), c.relays...)
+
+	for rumor := range nip17.ListenForMessages(ctx, c.pool, c.signer, urls, since) {
+		// Gift wraps are backdated, so filter on the real message time
+		if rumor.CreatedAt.Time().Before(start) || rumor.Kind != nostr.KindDirectMessage {
+			continue
+		}
+		if rumor.PubKey == pubkey {
+			continue // Copies of our own messages
+		}
+		handle(Message{
+			ID:      rumor.ID,
+			From:    rumor.PubKey,
+			Content: rumor.Content,
+			Direct:  true,
+			Private: true,
+		})
+	}
+}
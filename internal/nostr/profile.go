@@ -0,0 +1,241 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// relayListTTL is how long another user's relay list is cached
+const relayListTTL = time.Hour
+
+// Profile is the bot's NIP-01 profile metadata
+// Empty fields are left as they are on the relays.
+type Profile struct {
+	Name    string
+	About   string
+	Picture string // Image URL
+	NIP05   string // user@domain identifier
+	LUD16   string // Lightning address for zaps
+}
+
+// cachedRelays is a user's inbox relays from their NIP-65 list
+type cachedRelays struct {
+	relays    []string
+	fetchedAt time.Time
+}
+
+// PublishProfile publishes the profile (kind 0) if it differs from the
+// one on our relays. Fields set by other clients, such as a banner, are
+// kept.
+func (c *Client) PublishProfile(p Profile) error {
+	if p == (Profile{}) {
+		return nil
+	}
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 15*time.Second)
+	defer cancel()
+	current, err := c.fetchLatest(ctx, c.relays, nostr.Filter{
+		Kinds:   []int{nostr.KindProfileMetadata},
+		Authors: []string{c.pubkey},
+	})
+	if err != nil {
+		// Publishing now could replace a profile we did not get to see
+		return fmt.Errorf("failed to fetch current profile: %w", err)
+	}
+
+	meta := make(map[string]any)
+	if current != nil {
+		if err := json.Unmarshal([]byte(current.Content), &meta); err != nil {
+			meta = make(map[string]any) // Replace a malformed profile
+		}
+	}
+	changed := current == nil
+	for key, value := range map[string]string{
+		"name":    p.Name,
+		"about":   p.About,
+		"picture": p.Picture,
+		"nip05":   p.NIP05,
+		"lud16":   p.LUD16,
+	} {
+		if value != "" && meta[key] != value {
+			meta[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+	log.Println("Publishing profile")
+	return c.publishEvent(nostr.Event{
+		Kind:      nostr.KindProfileMetadata,
+		Content:   string(content),
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+	})
+}
+
+// PublishRelayList publishes our relays as a NIP-65 relay list (kind
+// 10002) for reading and writing, if it differs from the one published
+func (c *Client) PublishRelayList() error {
+	if c.signer == nil {
+		return fmt.Errorf("secret key not configured")
+	}
+
+	tags := make(nostr.Tags, 0, len(c.relays))
+	for _, url := range c.relays {
+		tags = append(tags, nostr.Tag{"r", nostr.NormalizeURL(url)})
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 15*time.Second)
+	defer cancel()
+	current, err := c.fetchLatest(ctx, c.relays, nostr.Filter{
+		Kinds:   []int{nostr.KindRelayListMetadata},
+		Authors: []string{c.pubkey},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch current relay list: %w", err)
+	}
+	if current != nil && slices.EqualFunc(current.Tags, tags, slices.Equal) {
+		return nil
+	}
+
+	log.Println("Publishing relay list")
+	return c.publishEvent(nostr.Event{
+		Kind:      nostr.KindRelayListMetadata,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Tags:      tags,
+	})
+}
+
+// inboxRelays returns the relays a user reads from according to their
+// NIP-65 relay list, or nil if they have not published one
+func (c *Client) inboxRelays(ctx context.Context, pubkey string) []string {
+	c.mu.Lock()
+	cached, ok := c.relayLists[pubkey]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < relayListTTL {
+		return cached.relays
+	}
+
+	var relays []string
+	list, err := c.fetchLatest(ctx, c.relays, nostr.Filter{
+		Kinds:   []int{nostr.KindRelayListMetadata},
+		Authors: []string{pubkey},
+	})
+	if err != nil {
+		// Try again next time rather than caching a list we did not get
+		log.Printf("Failed to fetch relay list of %s: %v", pubkey, err)
+		return cached.relays
+	}
+	if list != nil {
+		for _, tag := range list.Tags {
+			if len(tag) < 2 || tag[0] != "r" || !nostr.IsValidRelayURL(tag[1]) {
+				continue
+			}
+			if len(tag) == 2 || tag[2] == "read" {
+				relays = append(relays, nostr.NormalizeURL(tag[1]))
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.relayLists[pubkey] = cachedRelays{relays: relays, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return relays
+}
+
+// fetchLatest returns the newest event matching filter, or nil if the
+// relays have none. It fails if no event was found and a relay did not
+// answer, since the event may be on that relay.
+func (c *Client) fetchLatest(ctx context.Context, urls []string, filter nostr.Filter) (*nostr.Event, error) {
+	if c.pool == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		latest *nostr.Event
+		errs   []error
+	)
+	for _, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events, err := c.queryRelay(ctx, url, filter)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			}
+			for _, ev := range events {
+				if latest == nil || ev.CreatedAt > latest.CreatedAt {
+					latest = ev
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if latest == nil && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return latest, nil
+}
+
+// queryRelay returns the stored events matching filter on one relay
+// Unlike the pool's fetches it reports a relay that fails or does not
+// finish answering before ctx ends.
+func (c *Client) queryRelay(ctx context.Context, url string, filter nostr.Filter) ([]*nostr.Event, error) {
+	relay, err := c.pool.EnsureRelay(url)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := relay.Subscribe(ctx, nostr.Filters{filter})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsub()
+
+	var events []*nostr.Event
+	for {
+		select {
+		case ev, ok := <-sub.Events:
+			if !ok {
+				return nil, errors.New("subscription ended before EOSE")
+			}
+			events = append(events, ev)
+		case <-sub.EndOfStoredEvents:
+			// Stored events are queued before EOSE is signalled
+			for {
+				select {
+				case ev := <-sub.Events:
+					if ev != nil {
+						events = append(events, ev)
+					}
+				default:
+					return events, nil
+				}
+			}
+		case reason := <-sub.ClosedReason:
+			return nil, fmt.Errorf("closed by relay: %s", reason)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no answer: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package nostr
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestPublishProfileKeepsFields(t *testing.T) {
+	relay := newTestRelay(t, 0)
+	sk := nostr.GeneratePrivateKey()
+	c := newTestClient(t, Config{SecretKey: sk, Relays: []string{relay.URL}})
+
+	// A profile edited in another client
+	existing := nostr.Event{
+		Kind:      nostr.KindProfileMetadata,
+		Content:   `{"name":"old","picture":"https://example.com/a.png","lud16":"bot@example.com"}`,
+		CreatedAt: nostr.Timestamp(time.Now().Add(-time.Hour).Unix()),
+	}
+	existing.Sign(sk)
+	relay.store(existing)
+
+	if err := c.PublishProfile(Profile{Name: "komikan"}); err != nil {
+		t.Fatal(err)
+	}
+	got := relay.query(nostr.Filters{{Kinds: []int{nostr.KindProfileMetadata}}})
+	var meta map[string]string
+	if len(got) != 1 || json.Unmarshal([]byte(got[0].Content), &meta) != nil {
+		t.Fatalf("profiles on relay = %v", got)
+	}
+	if meta["name"] != "komikan" || meta["picture"] != "https://example.com/a.png" || meta["lud16"] != "bot@example.com" {
+		t.Errorf("published profile = %v, want the name updated and other fields kept", meta)
+	}
+}
+
+func TestPublishProfileSkipsWhenRelayFails(t *testing.T) {
+	relay := newTestRelay(t, 0)
+	relay.refuse = true
+	c := newTestClient(t, Config{Relays: []string{relay.URL}})
+
+	if err := c.PublishProfile(Profile{Name: "komikan"}); err == nil {
+		t.Fatal("PublishProfile published without seeing the current profile")
+	}
+	if got := relay.query(nostr.Filters{{Kinds: []int{nostr.KindProfileMetadata}}}); len(got) != 0 {
+		t.Errorf("profile published: %v", got)
+	}
+}
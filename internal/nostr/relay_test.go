@@ -0,0 +1,130 @@
+package nostr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testRelay is an in-memory relay that keeps the latest version of
+// replaceable events and answers at most limit events per request
+type testRelay struct {
+	URL    string
+	limit  int
+	refuse bool // Close every request with an error
+
+	mu       sync.Mutex
+	events   []nostr.Event
+	received map[string]int // Times each event ID was published
+	conns    int            // Open connections
+}
+
+func newTestRelay(t *testing.T, limit int) *testRelay {
+	t.Helper()
+	r := &testRelay{limit: limit, received: make(map[string]int)}
+	srv := httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(srv.Close)
+	r.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return r
+}
+
+func (r *testRelay) serve(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	r.mu.Lock()
+	r.conns++
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.conns--
+		r.mu.Unlock()
+	}()
+
+	ctx := req.Context()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var replies []nostr.Envelope
+		switch env := nostr.ParseMessage(string(data)).(type) {
+		case *nostr.EventEnvelope:
+			r.store(env.Event)
+			replies = append(replies, &nostr.OKEnvelope{EventID: env.ID, OK: true})
+		case *nostr.ReqEnvelope:
+			if r.refuse {
+				replies = append(replies, &nostr.ClosedEnvelope{SubscriptionID: env.SubscriptionID, Reason: "error: unavailable"})
+				break
+			}
+			for _, ev := range r.query(env.Filters) {
+				id := env.SubscriptionID
+				replies = append(replies, &nostr.EventEnvelope{SubscriptionID: &id, Event: ev})
+			}
+			eose := nostr.EOSEEnvelope(env.SubscriptionID)
+			replies = append(replies, &eose)
+		}
+		for _, reply := range replies {
+			out, _ := json.Marshal(reply)
+			if err := conn.Write(ctx, websocket.MessageText, out); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (r *testRelay) store(ev nostr.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received[ev.ID]++
+	if nostr.IsReplaceableKind(ev.Kind) || nostr.IsAddressableKind(ev.Kind) {
+		d := ev.Tags.GetD()
+		r.events = slices.DeleteFunc(r.events, func(old nostr.Event) bool {
+			return old.Kind == ev.Kind && old.PubKey == ev.PubKey && old.Tags.GetD() == d
+		})
+	}
+	r.events = append(r.events, ev)
+}
+
+// query returns the newest matching events, at most limit of them
+func (r *testRelay) query(filters nostr.Filters) []nostr.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []nostr.Event
+	for _, ev := range r.events {
+		if filters.Match(&ev) {
+			matched = append(matched, ev)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b nostr.Event) int { return int(b.CreatedAt - a.CreatedAt) })
+	if r.limit > 0 && len(matched) > r.limit {
+		matched = matched[:r.limit]
+	}
+	return matched
+}
+
+// newTestClient connects a client with a new key to relays
+func newTestClient(t *testing.T, cfg Config) *Client {
+	t.Helper()
+	if cfg.SecretKey == "" {
+		cfg.SecretKey = nostr.GeneratePrivateKey()
+	}
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Disconnect)
+	return c
+}
@@ -0,0 +1,178 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Reconnect backoff: doubles from minBackoff after each failed attempt
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 10 * time.Minute
+)
+
+// relayConn is a publishing connection to one relay, reconnected on
+// demand with exponential backoff
+type relayConn struct {
+	url   string
+	ctx   context.Context // Lifetime of the connection: the client's
+	keyer nostr.Keyer     // Answers NIP-42 AUTH challenges
+
+	mu       sync.Mutex
+	relay    *nostr.Relay
+	failures int       // Consecutive failed connection attempts
+	retryAt  time.Time // No connection attempts before this
+	notice   string    // Last NOTICE from the relay
+	lastErr  string    // Last connection or publish error
+}
+
+// RelayStatus describes the state of a relay connection
+type RelayStatus struct {
+	URL        string
+	Connected  bool
+	Failures   int
+	RetryAt    time.Time // Zero unless backing off
+	LastNotice string
+	LastError  string
+}
+
+// conn returns the connection state for a relay, creating it if needed
+func (c *Client) conn(url string) *relayConn {
+	url = nostr.NormalizeURL(url)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rc, ok := c.conns[url]
+	if !ok {
+		rc = &relayConn{url: url, ctx: c.ctx, keyer: c.signer}
+		c.conns[url] = rc
+	}
+	return rc
+}
+
+// publisher returns the connection to publish to a relay on
+// Configured relays share a lasting connection. Other relays, such as a
+// recipient's inbox relays, get a connection of their own that the
+// caller closes after delivery; temporary is true for those.
+func (c *Client) publisher(url string) (rc *relayConn, temporary bool) {
+	url = nostr.NormalizeURL(url)
+	for _, configured := range c.relays {
+		if nostr.NormalizeURL(configured) == url {
+			return c.conn(url), false
+		}
+	}
+	return &relayConn{url: url, ctx: c.ctx, keyer: c.signer}, true
+}
+
+// ensure returns a live connection, reconnecting if it dropped
+// While backing off after failures it returns an error without trying
+func (rc *relayConn) ensure() (*nostr.Relay, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.relay != nil && rc.relay.IsConnected() {
+		return rc.relay, nil
+	}
+	if time.Now().Before(rc.retryAt) {
+		return nil, fmt.Errorf("reconnecting in %s", time.Until(rc.retryAt).Round(time.Second))
+	}
+
+	relay := nostr.NewRelay(rc.ctx, rc.url, nostr.WithNoticeHandler(func(notice string) {
+		log.Printf("NOTICE from %s: %s", rc.url, notice)
+		rc.mu.Lock()
+		rc.notice = notice
+		rc.mu.Unlock()
+	}))
+	connectCtx, cancel := context.WithTimeout(rc.ctx, 15*time.Second)
+	defer cancel()
+	if err := relay.Connect(connectCtx); err != nil {
+		rc.failures++
+		backoff := min(minBackoff<<(rc.failures-1), maxBackoff)
+		rc.retryAt = time.Now().Add(backoff)
+		rc.lastErr = err.Error()
+		return nil, err
+	}
+
+	if rc.failures > 0 {
+		log.Printf("Reconnected to %s after %d failed attempt(s)", rc.url, rc.failures)
+	}
+	rc.relay = relay
+	rc.failures = 0
+	rc.retryAt = time.Time{}
+	return relay, nil
+}
+
+// publish sends an event and waits for the relay's OK, authenticating
+// first if the relay asks for it. A relay answering that it already has
+// the event counts as accepted.
+func (rc *relayConn) publish(ctx context.Context, ev nostr.Event) error {
+	relay, err := rc.ensure()
+	if err != nil {
+		return err
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = relay.Publish(pubCtx, ev)
+	if err != nil && strings.HasPrefix(err.Error(), "auth-required:") && rc.keyer != nil {
+		authErr := relay.Auth(pubCtx, func(ae *nostr.Event) error { return rc.keyer.SignEvent(pubCtx, ae) })
+		if authErr == nil {
+			err = relay.Publish(pubCtx, ev)
+		}
+	}
+	switch {
+	case err != nil && strings.Contains(err.Error(), "duplicate:"):
+		err = nil
+	case err == nil && !relay.IsConnected():
+		// The connection dropped before an OK arrived
+		err = errors.New("connection lost before OK")
+	}
+
+	rc.mu.Lock()
+	if err != nil {
+		rc.lastErr = err.Error()
+	}
+	rc.mu.Unlock()
+	return err
+}
+
+func (rc *relayConn) close() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.relay != nil {
+		rc.relay.Close()
+		rc.relay = nil
+	}
+}
+
+func (rc *relayConn) status() RelayStatus {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	st := RelayStatus{
+		URL:        rc.url,
+		Connected:  rc.relay != nil && rc.relay.IsConnected(),
+		Failures:   rc.failures,
+		LastNotice: rc.notice,
+		LastError:  rc.lastErr,
+	}
+	if time.Now().Before(rc.retryAt) {
+		st.RetryAt = rc.retryAt
+	}
+	return st
+}
+
+// RelayStatuses reports the connection state of the configured relays
+func (c *Client) RelayStatuses() []RelayStatus {
+	statuses := make([]RelayStatus, 0, len(c.relays))
+	for _, url := range c.relays {
+		statuses = append(statuses, c.conn(url).status())
+	}
+	return statuses
+}
@@ -0,0 +1,227 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip46"
+	"github.com/nbd-wtf/go-nostr/nip49"
+)
+
+// Signer holds the bot's key: it signs events and encrypts messages
+// (NIP-44 through nostr.Keyer, NIP-04 for legacy direct messages)
+type Signer interface {
+	nostr.Keyer
+	EncryptNIP04(ctx context.Context, plaintext, recipient string) (string, error)
+	DecryptNIP04(ctx context.Context, ciphertext, sender string) (string, error)
+}
+
+// LocalSigner signs with a secret key held in memory
+type LocalSigner struct {
+	keyer.KeySigner
+	sk string
+}
+
+// NewLocalSigner creates a signer from an nsec or hex secret key
+func NewLocalSigner(secretKey string) (*LocalSigner, error) {
+	if strings.HasPrefix(secretKey, "nsec") {
+		_, v, err := nip19.Decode(secretKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode nsec: %w", err)
+		}
+		secretKey = v.(string)
+	}
+	ks, err := keyer.NewPlainKeySigner(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	return &LocalSigner{KeySigner: ks, sk: secretKey}, nil
+}
+
+// EncryptNIP04 encrypts a direct message for recipient
+func (s *LocalSigner) EncryptNIP04(_ context.Context, plaintext, recipient string) (string, error) {
+	shared, err := nip04.ComputeSharedSecret(recipient, s.sk)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	return nip04.Encrypt(plaintext, shared)
+}
+
+// DecryptNIP04 decrypts a direct message from sender
+func (s *LocalSigner) DecryptNIP04(_ context.Context, ciphertext, sender string) (string, error) {
+	shared, err := nip04.ComputeSharedSecret(sender, s.sk)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	return nip04.Decrypt(ciphertext, shared)
+}
+
+// BunkerSigner asks a NIP-46 remote signer to sign and encrypt, so the
+// secret key never reaches this machine
+type BunkerSigner struct {
+	keyer.BunkerSigner
+	bunker *nip46.BunkerClient
+}
+
+// NewBunkerSigner connects to the remote signer of a bunker:// URL
+// clientKey identifies this installation to the signer, which may ask
+// for the connection to be approved first; revoking it there cuts the
+// bot off without changing the bot's key. ctx bounds the connection
+// attempt.
+func NewBunkerSigner(ctx context.Context, bunkerURL, clientKey string) (*BunkerSigner, error) {
+	u, err := url.Parse(bunkerURL)
+	if err != nil || u.Scheme != "bunker" {
+		return nil, fmt.Errorf("invalid bunker URL: must be bunker://<pubkey>?relay=...")
+	}
+	target := u.Host
+	relays := u.Query()["relay"]
+	if !nostr.IsValidPublicKey(target) {
+		return nil, fmt.Errorf("invalid bunker URL: %q is not a public key", target)
+	}
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("invalid bunker URL: no relay")
+	}
+
+	// The client listens for answers for as long as the process runs
+	bunker := nip46.NewBunker(context.Background(), clientKey, target, relays, nil, func(authURL string) {
+		log.Printf("Approve the connection in your signer: %s", authURL)
+	})
+
+	// The one-time secret may have been used by an earlier run, so an
+	// error here only matters if the signer does not know us either
+	if _, err := bunker.RPC(ctx, "connect", []string{target, u.Query().Get("secret")}); err != nil {
+		if pingErr := bunker.Ping(ctx); pingErr != nil {
+			return nil, fmt.Errorf("failed to connect to signer: %w", err)
+		}
+	}
+	return &BunkerSigner{BunkerSigner: keyer.NewBunkerSignerFromBunkerClient(bunker), bunker: bunker}, nil
+}
+
+// Decrypt asks the signer to decrypt a NIP-44 message from sender
+// keyer.BunkerSigner sends an encrypt request here, so it is replaced.
+func (s *BunkerSigner) Decrypt(ctx context.Context, ciphertext, sender string) (string, error) {
+	return s.bunker.NIP44Decrypt(ctx, sender, ciphertext)
+}
+
+// EncryptNIP04 asks the signer to encrypt a direct message for recipient
+func (s *BunkerSigner) EncryptNIP04(ctx context.Context, plaintext, recipient string) (string, error) {
+	return s.bunker.NIP04Encrypt(ctx, recipient, plaintext)
+}
+
+// DecryptNIP04 asks the signer to decrypt a direct message from sender
+func (s *BunkerSigner) DecryptNIP04(ctx context.Context, ciphertext, sender string) (string, error) {
+	return s.bunker.NIP04Decrypt(ctx, sender, ciphertext)
+}
+
+// SignerConfig selects the bot's signer
+type SignerConfig struct {
+	SecretKey  string                 // nsec, hex or NIP-49 ncryptsec
+	Passphrase func() (string, error) // Unlocks an ncryptsec SecretKey
+
+	Bunker              string // NIP-46 bunker:// URL; used instead of SecretKey
+	BunkerClientKeyFile string // Created on first use
+}
+
+// NewSigner returns a bunker signer when a bunker URL is set, and
+// otherwise a local signer, unlocking an ncryptsec key in memory
+func NewSigner(ctx context.Context, cfg SignerConfig) (Signer, error) {
+	if cfg.Bunker != "" {
+		clientKey, err := loadClientKey(cfg.BunkerClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewBunkerSigner(ctx, cfg.Bunker, clientKey)
+	}
+
+	if cfg.SecretKey == "" {
+		return nil, fmt.Errorf("secret key not configured")
+	}
+	if !IsEncryptedKey(cfg.SecretKey) {
+		return NewLocalSigner(cfg.SecretKey)
+	}
+	if cfg.Passphrase == nil {
+		return nil, fmt.Errorf("secret key is encrypted and no passphrase is available")
+	}
+	pass, err := cfg.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := DecryptKey(cfg.SecretKey, pass)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalSigner(secretKey)
+}
+
+// IsEncryptedKey reports whether key is a NIP-49 ncryptsec
+func IsEncryptedKey(key string) bool {
+	return strings.HasPrefix(key, "ncryptsec1")
+}
+
+// EncryptKey encrypts an nsec or hex secret key with a passphrase as a
+// NIP-49 ncryptsec. fresh marks a key that has never been stored in
+// clear; any other key has been in a config file or the environment and
+// is marked as handled insecurely.
+func EncryptKey(secretKey, passphrase string, fresh bool) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	signer, err := NewLocalSigner(secretKey)
+	if err != nil {
+		return "", err
+	}
+	security := nip49.KnownToHaveBeenHandledInsecurely
+	if fresh {
+		security = nip49.NotKnownToHaveBeenHandledInsecurely
+	}
+	// scrypt work factor 2^16, which needs 64 MiB to unlock
+	ncryptsec, err := nip49.Encrypt(signer.sk, passphrase, 16, security)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret key: %w", err)
+	}
+	return ncryptsec, nil
+}
+
+// DecryptKey unlocks a NIP-49 ncryptsec and returns the hex secret key
+func DecryptKey(ncryptsec, passphrase string) (string, error) {
+	secretKey, err := nip49.Decrypt(ncryptsec, passphrase)
+	if err != nil {
+		// The error does not depend on the key or the passphrase
+		return "", fmt.Errorf("failed to unlock secret key (wrong passphrase?): %w", err)
+	}
+	return secretKey, nil
+}
+
+// loadClientKey reads the bunker client key, generating it if needed
+func loadClientKey(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(data))
+		if _, err := nostr.GetPublicKey(key); err != nil {
+			return "", fmt.Errorf("invalid bunker client key in %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read bunker client key: %w", err)
+	}
+
+	key := nostr.GeneratePrivateKey()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write bunker client key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,453 @@
+// Package relaysync keeps the database in sync with Nostr relays, so a
+// library can be rebuilt from relays and shared by installations using
+// the same key.
+//
+// Records are grouped into a fixed number of buckets. Each bucket is
+// published as one encrypted application data event holding its records
+// with their modification times; conflicting changes to the same record
+// are resolved by last writer wins.
+package relaysync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+	"github.com/kench/komikan-go/internal/nostr"
+)
+
+const (
+	// buckets is the number of events the database is spread over
+	buckets = 256
+
+	// maxContent is the NIP-44 plaintext limit
+	maxContent = 65535
+
+	// pullOverlap re-reads events around the last pull to allow for
+	// relays that received them late
+	pullOverlap = time.Hour
+)
+
+// Key prefixes holding sync state
+const (
+	statePrefix = "sync:record:"
+	dirtyPrefix = "sync:dirty:" // Buckets with changes not yet published
+	pulledKey   = "sync:pulled"
+)
+
+// localPrefixes are never synced: schema metadata, sync state and events
+// waiting to be published belong to one installation
+var localPrefixes = []string{db.MetaPrefix, "sync:", "outbox:"}
+
+// state is the local version of a record
+type state struct {
+	Hash      string `json:"hash"`       // SHA-256 of the value, empty when deleted
+	UpdatedAt int64  `json:"updated_at"` // Unix milliseconds
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+// record is a version of a record as published
+type record struct {
+	Value     json.RawMessage `json:"v,omitempty"`
+	UpdatedAt int64           `json:"t"`
+	Deleted   bool            `json:"d,omitempty"`
+}
+
+// bucket is the content of one published event
+type bucket struct {
+	SchemaVersion int               `json:"schema_version"`
+	Records       map[string]record `json:"records"`
+}
+
+// Report summarizes a sync
+type Report struct {
+	Changed   int // Local changes found
+	Pulled    int // Records updated from relays
+	Published int // Buckets published
+}
+
+// Syncer syncs a database with relays through a Nostr client
+type Syncer struct {
+	db     *db.DB
+	client *nostr.Client
+}
+
+// New creates a syncer
+// The client must be connected and have a signer.
+func New(database *db.DB, client *nostr.Client) *Syncer {
+	return &Syncer{db: database, client: client}
+}
+
+// Sync records local changes, applies newer records from the relays and
+// publishes the buckets the relays are missing changes in. On an empty
+// database it restores everything published.
+func (s *Syncer) Sync(ctx context.Context) (*Report, error) {
+	start := time.Now()
+	report := &Report{}
+	dirty := make(map[int]bool)
+	published := make(map[int]time.Time) // Date of the bucket versions pulled
+
+	changed, err := s.scan(start)
+	if err != nil {
+		return report, fmt.Errorf("failed to scan database: %w", err)
+	}
+	report.Changed = changed
+	if err := s.loadDirty(dirty); err != nil {
+		return report, err
+	}
+
+	pulled, err := s.pull(ctx, dirty, published)
+	if err != nil {
+		return report, err
+	}
+	report.Pulled = pulled
+
+	for n := range dirty {
+		// A replacement must be newer than the version it replaces
+		at := start
+		if prev, ok := published[n]; ok && !at.After(prev) {
+			at = prev.Add(time.Second)
+		}
+		if err := s.push(n, at); err != nil {
+			return report, fmt.Errorf("failed to publish bucket %d: %w", n, err)
+		}
+		if err := s.db.Delete([]byte(dirtyPrefix + strconv.Itoa(n))); err != nil {
+			return report, err
+		}
+		report.Published++
+	}
+
+	if err := s.db.Set([]byte(pulledKey), []byte(strconv.FormatInt(start.Unix(), 10))); err != nil {
+		return report, fmt.Errorf("failed to record sync time: %w", err)
+	}
+	return report, nil
+}
+
+// scan compares the database with the recorded states and dates every
+// new, changed or deleted record at now. Records are read a batch at a
+// time and their states committed in bounded transactions, together with
+// the buckets they make dirty, so a scan cut short loses no changes.
+func (s *Syncer) scan(now time.Time) (int, error) {
+	changed := 0
+	mark := func(txn *db.Txn, key string, st state) error {
+		if err := putState(txn, key, st); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(dirtyPrefix+strconv.Itoa(bucketOf(key))), nil); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	}
+
+	// New and changed records
+	err := s.db.UpdateEach("", func(txn *db.Txn, e db.Entry) error {
+		if !syncable(e.Key) {
+			return nil
+		}
+		hash := hashValue(e.Value)
+		var st state
+		err := txn.GetJSON(statePrefix+e.Key, &st)
+		switch {
+		case err == nil && st.Hash == hash && !st.Deleted:
+			return nil
+		case err != nil && !db.IsNotFound(err):
+			return fmt.Errorf("failed to read state of %s: %w", e.Key, err)
+		}
+		return mark(txn, e.Key, state{Hash: hash, UpdatedAt: now.UnixMilli()})
+	})
+	if err != nil {
+		return changed, err
+	}
+
+	// Deleted records
+	err = s.db.UpdateEach(statePrefix, func(txn *db.Txn, e db.Entry) error {
+		var st state
+		if err := json.Unmarshal(e.Value, &st); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", e.Key, err)
+		}
+		key := strings.TrimPrefix(e.Key, statePrefix)
+		if st.Deleted {
+			return nil
+		}
+		if _, err := txn.Get([]byte(key)); !db.IsNotFound(err) {
+			return err
+		}
+		return mark(txn, key, state{UpdatedAt: now.UnixMilli(), Deleted: true})
+	})
+	return changed, err
+}
+
+// loadDirty adds the buckets recorded as dirty by scan to dirty
+func (s *Syncer) loadDirty(dirty map[int]bool) error {
+	var keys []string
+	err := s.db.View(func(txn *db.Txn) error {
+		var err error
+		keys, err = txn.ListPrefixKeys(dirtyPrefix)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read dirty buckets: %w", err)
+	}
+	for _, key := range keys {
+		n, err := strconv.Atoi(strings.TrimPrefix(key, dirtyPrefix))
+		if err != nil || n < 0 || n >= buckets {
+			continue
+		}
+		dirty[n] = true
+	}
+	return nil
+}
+
+// pull applies records from the relays that are newer than the local
+// ones, and marks buckets whose published version is behind as dirty.
+// published receives the date of each bucket version found.
+func (s *Syncer) pull(ctx context.Context, dirty map[int]bool, published map[int]time.Time) (int, error) {
+	var since time.Time
+	if data, err := s.db.Get([]byte(pulledKey)); err == nil {
+		if sec, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+			since = time.Unix(sec, 0).Add(-pullOverlap)
+		}
+	} else if !db.IsNotFound(err) {
+		return 0, err
+	}
+
+	ids := make(map[string]int, buckets)
+	list := make([]string, 0, buckets)
+	for n := range buckets {
+		ids[s.bucketID(n)] = n
+		list = append(list, s.bucketID(n))
+	}
+
+	fetched, err := s.client.FetchAppData(ctx, list, since)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := s.db.SchemaVersionOf()
+	if err != nil {
+		return 0, err
+	}
+
+	pulled := 0
+	for _, data := range fetched {
+		n, ok := ids[data.ID]
+		if !ok {
+			continue // Not ours
+		}
+		published[n] = data.CreatedAt
+		b, err := decodeBucket(data.Content)
+		if err != nil {
+			return pulled, fmt.Errorf("bucket %d: %w", n, err)
+		}
+		if b.SchemaVersion != version {
+			return pulled, fmt.Errorf("bucket %d has schema version %d, database has %d; upgrade both installations", n, b.SchemaVersion, version)
+		}
+
+		count, stale, err := s.apply(n, b)
+		pulled += count
+		if err != nil {
+			return pulled, err
+		}
+		if stale {
+			dirty[n] = true
+		}
+	}
+
+	if pulled > 0 {
+		if err := s.db.RebuildIndexes(); err != nil {
+			return pulled, fmt.Errorf("failed to rebuild indexes: %w", err)
+		}
+	}
+	return pulled, nil
+}
+
+// apply writes the records of a bucket that are newer than the local
+// ones. stale reports whether the bucket lacks local changes.
+func (s *Syncer) apply(n int, b *bucket) (count int, stale bool, err error) {
+	err = s.db.Update(func(txn *db.Txn) error {
+		states, err := loadStates(txn)
+		if err != nil {
+			return err
+		}
+
+		for key, rec := range b.Records {
+			if !syncable(key) || bucketOf(key) != n {
+				continue
+			}
+			st, ok := states[key]
+			switch {
+			case ok && rec.UpdatedAt < st.UpdatedAt:
+				stale = true
+				continue
+			case ok && rec.UpdatedAt == st.UpdatedAt:
+				continue
+			}
+
+			if rec.Deleted {
+				if err := txn.Delete([]byte(key)); err != nil {
+					return err
+				}
+				st = state{UpdatedAt: rec.UpdatedAt, Deleted: true}
+			} else {
+				if err := txn.Set([]byte(key), rec.Value); err != nil {
+					return err
+				}
+				st = state{Hash: hashValue(rec.Value), UpdatedAt: rec.UpdatedAt}
+			}
+			if err := putState(txn, key, st); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// Records the relays have never seen
+		for key := range states {
+			if _, ok := b.Records[key]; !ok && bucketOf(key) == n {
+				stale = true
+			}
+		}
+		return nil
+	})
+	return count, stale, err
+}
+
+// push publishes a bucket with every local record in it
+func (s *Syncer) push(n int, now time.Time) error {
+	version, err := s.db.SchemaVersionOf()
+	if err != nil {
+		return err
+	}
+	b := bucket{SchemaVersion: version, Records: make(map[string]record)}
+
+	err = s.db.View(func(txn *db.Txn) error {
+		states, err := loadStates(txn)
+		if err != nil {
+			return err
+		}
+		for key, st := range states {
+			if bucketOf(key) != n {
+				continue
+			}
+			rec := record{UpdatedAt: st.UpdatedAt, Deleted: st.Deleted}
+			if !st.Deleted {
+				value, err := txn.Get([]byte(key))
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", key, err)
+				}
+				rec.Value = value
+			}
+			b.Records[key] = rec
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	content, err := encodeBucket(b)
+	if err != nil {
+		return err
+	}
+	return s.client.PublishAppData(s.bucketID(n), content, now)
+}
+
+// bucketID is the d tag of a bucket
+func (s *Syncer) bucketID(n int) string {
+	return s.client.BlindID("komikan-sync:" + strconv.Itoa(n))
+}
+
+// syncable reports whether a storage key is synced
+// Index entries are rebuilt from the records instead.
+func syncable(key string) bool {
+	if db.IsIndexKey(key) {
+		return false
+	}
+	for _, p := range localPrefixes {
+		if strings.HasPrefix(key, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func bucketOf(key string) int {
+	sum := sha256.Sum256([]byte(key))
+	return int(sum[0]) % buckets
+}
+
+func hashValue(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadStates(txn *db.Txn) (map[string]state, error) {
+	entries, err := txn.ListPrefixEntries(statePrefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]state, len(entries))
+	for _, e := range entries {
+		var st state
+		if err := json.Unmarshal(e.Value, &st); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", e.Key, err)
+		}
+		states[strings.TrimPrefix(e.Key, statePrefix)] = st
+	}
+	return states, nil
+}
+
+func putState(txn *db.Txn, key string, st state) error {
+	return txn.SetJSON(statePrefix+key, st)
+}
+
+// encodeBucket compresses a bucket to fit in one encrypted event
+func encodeBucket(b bucket) (string, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode bucket: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return "", fmt.Errorf("failed to compress bucket: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress bucket: %w", err)
+	}
+	content := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(content) > maxContent {
+		return "", fmt.Errorf("bucket of %d records is too large to publish (%d bytes)", len(b.Records), len(content))
+	}
+	return content, nil
+}
+
+func decodeBucket(content string) (*bucket, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode bucket: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bucket: %w", err)
+	}
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bucket: %w", err)
+	}
+	var b bucket
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bucket: %w", err)
+	}
+	return &b, nil
+}
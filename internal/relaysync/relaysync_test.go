@@ -0,0 +1,72 @@
+package relaysync
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kench/komikan-go/internal/db"
+)
+
+func TestScanLargeLibrary(t *testing.T) {
+	// A small memtable keeps Badger's transaction limit low
+	d, err := db.NewDB(db.Config{
+		Path:           filepath.Join(t.TempDir(), "komikan.db"),
+		Badger:         db.BadgerOptions{MemTableSizeMB: 8},
+		SkipMigrations: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	const n = 20000
+	for start := 0; start < n; start += 500 {
+		err := d.Update(func(txn *db.Txn) error {
+			for i := start; i < start+500; i++ {
+				value := fmt.Sprintf(`{"isbn":"%d","notes":"%0100d"}`, i, i)
+				if err := txn.Set([]byte(fmt.Sprintf("manga:isbn:%05d", i)), []byte(value)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Syncer{db: d}
+	changed, err := s.scan(time.Now())
+	if err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	if changed != n {
+		t.Errorf("first scan found %d changes, want %d", changed, n)
+	}
+	dirty := make(map[int]bool)
+	if err := s.loadDirty(dirty); err != nil {
+		t.Fatal(err)
+	}
+	if len(dirty) != buckets {
+		t.Errorf("%d dirty buckets, want all %d", len(dirty), buckets)
+	}
+
+	if changed, err := s.scan(time.Now()); err != nil || changed != 0 {
+		t.Errorf("rescan = %d, %v; want no changes", changed, err)
+	}
+
+	for _, key := range []string{"manga:isbn:00001", "manga:isbn:02999"} {
+		if err := d.Delete([]byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changed, err := s.scan(time.Now()); err != nil || changed != 2 {
+		t.Errorf("scan after deleting 2 records = %d, %v; want 2", changed, err)
+	}
+	var st state
+	if err := d.GetJSON(statePrefix+"manga:isbn:00001", &st); err != nil || !st.Deleted {
+		t.Errorf("state of deleted record = %+v, %v", st, err)
+	}
+}